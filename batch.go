@@ -0,0 +1,129 @@
+package onetable
+
+// Batch collects puts and deletes that OneTable.Write commits atomically.
+// The zero value is an empty batch ready to use.
+type Batch struct {
+	ops []batchOp
+	// data holds the values of all puts, in the order they are appended to
+	// the data file
+	data []byte
+}
+
+type batchOp struct {
+	key    string
+	start  int
+	length int
+	delete bool
+}
+
+// Put stores value under key when the batch is written. The value is copied.
+func (b *Batch) Put(key string, value []byte) {
+	b.ops = append(b.ops, batchOp{key: key, start: len(b.data), length: len(value)})
+	b.data = append(b.data, value...)
+}
+
+// Delete removes key when the batch is written
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+}
+
+// PutBytes is Put with a []byte key
+func (b *Batch) PutBytes(key []byte, value []byte) {
+	b.Put(string(key), value)
+}
+
+// DeleteBytes is Delete with a []byte key
+func (b *Batch) DeleteBytes(key []byte) {
+	b.Delete(string(key))
+}
+
+// Len returns the number of operations in the batch
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset empties the batch, keeping its buffers for reuse
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+	b.data = b.data[:0]
+}
+
+// Write commits all operations of the batch in order. The values are
+// appended to the data file in one write and the operations to the index
+// file as a single record, so that after a crash either all of them or none
+// are recovered. With SyncAlways the batch costs one fsync.
+//
+// Tables in the legacy csv format return ErrLegacyFormat.
+func (o *OneTable) Write(b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+
+	seq, err := o.appendBatch(b)
+	if err != nil {
+		return err
+	}
+
+	return o.commit(seq)
+}
+
+func (o *OneTable) appendBatch(b *Batch) (uint64, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.appendBatchLocked(b)
+}
+
+// appendBatchLocked writes the batch with lock held
+func (o *OneTable) appendBatchLocked(b *Batch) (uint64, error) {
+	if o.halfSwapped != nil {
+		return 0, o.halfSwapped
+	}
+
+	if !o.format.binary() {
+		return 0, ErrLegacyFormat
+	}
+
+	rec := indexRecord{batch: make([]indexRecord, len(b.ops))}
+	for i, op := range b.ops {
+		version := o.version + uint64(i) + 1
+		if op.delete {
+			rec.batch[i] = indexRecord{key: op.key, tombstone: true, valueMeta: valueMetadata{version: version}}
+			continue
+		}
+
+		value := b.data[op.start : op.start+op.length]
+		rec.batch[i] = indexRecord{key: op.key, valueMeta: valueMetadata{
+			offset:      o.offset + typeOffset(op.start),
+			length:      op.length,
+			checksum:    checksum(value),
+			checksummed: true,
+			version:     version,
+		}}
+	}
+
+	if err := o.writeValue(b.data); err != nil {
+		return 0, err
+	}
+
+	if err := o.writeRecord(rec); err != nil {
+		o.discardValues()
+		return 0, err
+	}
+
+	for _, entry := range rec.batch {
+		o.recordHistory(entry.key)
+		if entry.tombstone {
+			o.indexDelete(entry.key)
+			o.tombstones++
+		} else {
+			o.indexInsert(entry.key, entry.valueMeta)
+		}
+	}
+
+	o.offset = o.offset + typeOffset(len(b.data))
+	o.version += uint64(len(b.ops))
+	o.written++
+
+	return o.written, nil
+}
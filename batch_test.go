@@ -0,0 +1,122 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeTestBatch(t *testing.T, table *OneTable) {
+	var batch Batch
+	for i := 0; i < 10; i++ {
+		batch.Put(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	batch.Delete("before")
+
+	if err := table.Write(&batch); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestBatchWrite(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("before", []byte("value"))
+	writeTestBatch(t, table)
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, tbl := range []*OneTable{table, reopened} {
+		for i := 0; i < 10; i++ {
+			v, err := tbl.Get(fmt.Sprintf("key%d", i))
+			if err != nil || string(v) != fmt.Sprintf("value%d", i) {
+				t.Fatalf("Expected value%d, Got %s (%v)", i, v, err)
+			}
+		}
+
+		if tbl.Has("before") {
+			t.Fatal("Key deleted by the batch found")
+		}
+	}
+}
+
+func TestBatchAllOrNothing(t *testing.T) {
+	cut := map[string]func(folder string){
+		"torn index record": func(folder string) {
+			indexPath := path.Join(folder, indexFileName)
+			info, _ := os.Stat(indexPath)
+			os.Truncate(indexPath, info.Size()-10)
+		},
+		"missing value": func(folder string) {
+			dataPath := path.Join(folder, dataFileName)
+			info, _ := os.Stat(dataPath)
+			os.Truncate(dataPath, info.Size()-1)
+		},
+	}
+
+	for name, damage := range cut {
+		t.Run(name, func(t *testing.T) {
+			folder := t.TempDir()
+			table, err := New(folder, NewIndexHashTable())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			table.Insert("before", []byte("value"))
+			writeTestBatch(t, table)
+			table.Close()
+
+			damage(folder)
+
+			reopened, err := New(folder, NewIndexHashTable())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			if !reopened.Recovery().Repaired() {
+				t.Fatal("Expected a repair")
+			}
+
+			if !reopened.Has("before") {
+				t.Fatal("Delete of a torn batch was applied")
+			}
+
+			for i := 0; i < 10; i++ {
+				if reopened.Has(fmt.Sprintf("key%d", i)) {
+					t.Fatalf("Put of key%d of a torn batch was applied", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchLegacyFormat(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(path.Join(folder, dataFileName), []byte{}, 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte{}, 0644)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var batch Batch
+	batch.Put("a", []byte("val a"))
+
+	if err := table.Write(&batch); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	if table.Has("a") {
+		t.Fatal("Batch rejected by the legacy format was applied")
+	}
+}
@@ -0,0 +1,139 @@
+package onetable
+
+import "bytes"
+
+// condition decides with lock held whether a conditional write goes ahead,
+// given the current metadata of the key
+type condition func(valueMeta ValueMetadata, found bool) (bool, error)
+
+// writeIf checks cond and, if it holds, inserts value or deletes the key in
+// one step under lock. It reports whether the write happened.
+func (o *OneTable) writeIf(key string, cond condition, value []byte, remove bool) (bool, error) {
+	o.lock.Lock()
+
+	valueMeta, found := o.Index.Get(key)
+	ok, err := cond(valueMeta, found)
+	if err != nil || !ok {
+		o.lock.Unlock()
+		return false, err
+	}
+
+	var seq uint64
+	if remove {
+		seq, err = o.appendDeleteLocked(key)
+	} else {
+		seq, err = o.appendInsertLocked(key, value)
+	}
+
+	o.lock.Unlock()
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, o.commit(seq)
+}
+
+// valueEquals holds when the key exists with the given value. Holding lock
+// keeps Compact from moving the value while it is read.
+func (o *OneTable) valueEquals(key string, value []byte) condition {
+	return func(valueMeta ValueMetadata, found bool) (bool, error) {
+		if !found {
+			return false, nil
+		}
+
+		current, err := o.readValue(key, valueMeta)
+		if err != nil {
+			return false, err
+		}
+
+		return bytes.Equal(current, value), nil
+	}
+}
+
+// versionEquals holds when the key exists with the given version
+func (o *OneTable) versionEquals(version uint64) condition {
+	return func(valueMeta ValueMetadata, found bool) (bool, error) {
+		if err := o.checkVersioned(); err != nil {
+			return false, err
+		}
+
+		return found && valueMeta.Version() == version, nil
+	}
+}
+
+// checkVersioned returns ErrLegacyFormat for formats that do not store key
+// versions. Their versions are assigned anew on every load, so a version
+// seen before a reopen can come back for a different write. It is called
+// with lock or fileLock held.
+func (o *OneTable) checkVersioned() error {
+	if o.format != formatBinary {
+		return ErrLegacyFormat
+	}
+
+	return nil
+}
+
+// CompareAndSwap replaces the value of key with new if it currently is old.
+// It reports whether the value was replaced. A missing key never matches.
+func (o *OneTable) CompareAndSwap(key string, old []byte, new []byte) (bool, error) {
+	return o.writeIf(key, o.valueEquals(key, old), new, false)
+}
+
+// CompareAndSwapVersion replaces the value of key with new if the key is
+// still at version, as returned by GetWithVersion. It avoids reading and
+// comparing the current value. It reports whether the value was replaced.
+// Tables in a legacy format return ErrLegacyFormat.
+func (o *OneTable) CompareAndSwapVersion(key string, version uint64, new []byte) (bool, error) {
+	return o.writeIf(key, o.versionEquals(version), new, false)
+}
+
+// InsertIfAbsent inserts value only if key does not exist. It reports
+// whether the value was inserted.
+func (o *OneTable) InsertIfAbsent(key string, value []byte) (bool, error) {
+	absent := func(_ ValueMetadata, found bool) (bool, error) {
+		return !found, nil
+	}
+
+	return o.writeIf(key, absent, value, false)
+}
+
+// DeleteIfEquals deletes key if its value is value. It reports whether the
+// key was deleted.
+func (o *OneTable) DeleteIfEquals(key string, value []byte) (bool, error) {
+	return o.writeIf(key, o.valueEquals(key, value), nil, true)
+}
+
+// DeleteIfVersion deletes key if it is still at version. It reports whether
+// the key was deleted. Tables in a legacy format return ErrLegacyFormat.
+func (o *OneTable) DeleteIfVersion(key string, version uint64) (bool, error) {
+	return o.writeIf(key, o.versionEquals(version), nil, true)
+}
+
+// GetWithVersion returns the value of key together with its version, or
+// ErrNotFound. Every write of a key gives it a new, higher version, so an
+// unchanged version means the key was not written in the meantime, also
+// across Compact and reopening the table.
+//
+// Tables in the legacy csv format or in version 1 of the binary format do
+// not store versions and return ErrLegacyFormat until they are upgraded.
+func (o *OneTable) GetWithVersion(key string) ([]byte, uint64, error) {
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	if err := o.checkVersioned(); err != nil {
+		return nil, 0, err
+	}
+
+	valueMeta, found := o.Index.Get(key)
+	if !found {
+		return nil, 0, ErrNotFound
+	}
+
+	value, err := o.readValue(key, valueMeta)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return value, valueMeta.Version(), nil
+}
@@ -0,0 +1,196 @@
+package onetable
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if ok, err := table.CompareAndSwap("a", nil, []byte("1")); ok || err != nil {
+		t.Fatalf("Swapped a missing key (%v)", err)
+	}
+
+	if ok, _ := table.InsertIfAbsent("a", []byte("1")); !ok {
+		t.Fatal("InsertIfAbsent did not insert a missing key")
+	}
+
+	if ok, _ := table.InsertIfAbsent("a", []byte("2")); ok {
+		t.Fatal("InsertIfAbsent overwrote an existing key")
+	}
+
+	if ok, _ := table.CompareAndSwap("a", []byte("2"), []byte("3")); ok {
+		t.Fatal("Swapped a value that does not match")
+	}
+
+	if ok, _ := table.CompareAndSwap("a", []byte("1"), []byte("3")); !ok {
+		t.Fatal("Did not swap a matching value")
+	}
+
+	if ok, _ := table.DeleteIfEquals("a", []byte("1")); ok {
+		t.Fatal("Deleted a value that does not match")
+	}
+
+	if ok, _ := table.DeleteIfEquals("a", []byte("3")); !ok {
+		t.Fatal("Did not delete a matching value")
+	}
+
+	if table.Has("a") {
+		t.Fatal("Key found after DeleteIfEquals")
+	}
+}
+
+func TestCompareAndSwapVersion(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("1"))
+	_, v1, err := table.GetWithVersion("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("1"))
+	if ok, _ := table.CompareAndSwapVersion("a", v1, []byte("2")); ok {
+		t.Fatal("Swapped a stale version")
+	}
+
+	_, v2, _ := table.GetWithVersion("a")
+	if v2 <= v1 {
+		t.Fatalf("Expected version above %d, Got %d", v1, v2)
+	}
+
+	if ok, _ := table.CompareAndSwapVersion("a", v2, []byte("2")); !ok {
+		t.Fatal("Did not swap the current version")
+	}
+
+	_, v3, _ := table.GetWithVersion("a")
+
+	// a deleted and recreated key never gets a version back, also when
+	// compaction dropped the tombstone
+	table.Insert("b", []byte("b"))
+	table.Delete("a")
+	table.Snapshot()
+	table.Compact()
+
+	reopened, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, vb, _ := reopened.GetWithVersion("b")
+	reopened.Insert("a", []byte("1"))
+	_, v4, _ := reopened.GetWithVersion("a")
+
+	if v4 <= v3 || v4 <= vb+1 {
+		t.Fatalf("Versions did not grow across delete, compaction and reopen: %d, %d, %d", v3, vb, v4)
+	}
+
+	if ok, _ := reopened.DeleteIfVersion("a", v3); ok {
+		t.Fatal("Deleted a stale version")
+	}
+
+	if ok, _ := reopened.DeleteIfVersion("a", v4); !ok {
+		t.Fatal("Did not delete the current version")
+	}
+
+	if _, _, err := reopened.GetWithVersion("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, Got %v", err)
+	}
+}
+
+// TestCompareAndSwapCounter is meant to be run with -race
+func TestCompareAndSwapCounter(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexSkipList())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("counter", []byte{0})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for {
+					v, version, err := table.GetWithVersion("counter")
+					if err != nil {
+						t.Error(err.Error())
+						return
+					}
+
+					ok, err := table.CompareAndSwapVersion("counter", version, []byte{v[0] + 1})
+					if err != nil {
+						t.Error(err.Error())
+						return
+					}
+
+					if ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if v, _ := table.Get("counter"); v[0] != 160 {
+		t.Fatalf("Expected 160, Got %d", v[0])
+	}
+}
+
+func TestCompareAndSwapVersionLegacyFormat(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(path.Join(folder, dataFileName), []byte{}, 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte{}, 0644)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("1"))
+
+	// csv tables number versions anew on every load
+	if _, _, err := table.GetWithVersion("a"); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	if _, err := table.CompareAndSwapVersion("a", 1, []byte("2")); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	if _, err := table.DeleteIfVersion("a", 1); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	if v, _ := table.Get("a"); string(v) != "1" {
+		t.Fatalf("Expected 1, Got %s", v)
+	}
+
+	if err := table.Upgrade(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, version, err := table.GetWithVersion("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if ok, err := table.CompareAndSwapVersion("a", version, []byte("2")); !ok || err != nil {
+		t.Fatalf("Expected the upgraded table to swap, Got %t (%v)", ok, err)
+	}
+}
@@ -0,0 +1,41 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupted is returned (wrapped in a CorruptedError) when a value read
+// from the data file does not match the checksum recorded in the index
+var ErrCorrupted = errors.New("Value is corrupted")
+
+// CorruptedError names the key and data file offset of a corrupted value.
+// It matches ErrCorrupted with errors.Is.
+type CorruptedError struct {
+	Key    string
+	Offset int64
+}
+
+func (e *CorruptedError) Error() string {
+	return fmt.Sprintf("Value of key %s at offset %d is corrupted", e.Key, e.Offset)
+}
+
+func (e *CorruptedError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+func checksum(value []byte) uint32 {
+	return crc32.Checksum(value, castagnoli)
+}
+
+func verifyChecksum(key string, valueMeta ValueMetadata, value []byte) error {
+	sum, ok := valueMeta.Checksum()
+	if !ok || sum == checksum(value) {
+		return nil
+	}
+
+	return &CorruptedError{Key: key, Offset: valueMeta.Offset()}
+}
@@ -0,0 +1,72 @@
+package onetable
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestChecksumDetectsCorruption(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+
+	f, err := os.OpenFile(path.Join(folder, dataFileName), os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	f.WriteAt([]byte("X"), headerSize+7)
+	f.Close()
+
+	if v, err := table.Get("a"); err != nil || string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s (%v)", v, err)
+	}
+
+	_, err = table.Get("b")
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted, Got %v", err)
+	}
+
+	var corrupted *CorruptedError
+	if !errors.As(err, &corrupted) || corrupted.Key != "b" || corrupted.Offset != headerSize+5 {
+		t.Fatalf("Expected corruption of key b at offset %d, Got %v", headerSize+5, err)
+	}
+
+	if _, err := table.Between("a", "b"); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted from Between, Got %v", err)
+	}
+}
+
+func TestChecksumLegacyRecords(t *testing.T) {
+	folder := t.TempDir()
+
+	os.WriteFile(path.Join(folder, dataFileName), []byte("val a"), 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte("a,0,5\n"), 0644)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("b", []byte("val b")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for k, expected := range map[string]string{"a": "val a", "b": "val b"} {
+		v, err := reopened.Get(k)
+		if err != nil || string(v) != expected {
+			t.Fatalf("Expected %s, Got %s (%v)", expected, v, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -13,7 +14,8 @@ import (
 
 func main() {
 	pfolderPath := flag.String("folder", "", "Path to folder where data is/will be stored")
-	pindex := flag.String("index", "hashtable", "Index to use. Currently supported: [hashtable, bst]")
+	pindex := flag.String("index", "hashtable", "Index to use. Currently supported: [hashtable, bst, avl, btree, skiplist, art]")
+	create := flag.Bool("create", false, "Create the folder if it does not exist")
 	help := flag.Bool("help", false, "Print Help")
 
 	flag.Parse()
@@ -37,21 +39,38 @@ func main() {
 		index = onetable.NewIndexHashTable()
 	} else if *pindex == "bst" {
 		index = onetable.NewIndexBST()
+	} else if *pindex == "avl" {
+		index = onetable.NewIndexAVL()
+	} else if *pindex == "btree" {
+		index = onetable.NewIndexBTree(onetable.DefaultBTreeDegree)
+	} else if *pindex == "skiplist" {
+		index = onetable.NewIndexSkipList()
+	} else if *pindex == "art" {
+		index = onetable.NewIndexART()
 	} else {
 		printHelp()
 	}
 
-	t, err := onetable.New(*pfolderPath, index)
+	t, err := onetable.NewWithOptions(*pfolderPath, index, onetable.Options{CreateFolder: *create})
 	if err != nil {
-		panic(err.Error())
+		log.Fatal(err.Error())
 	}
+
+	if report := t.Recovery(); report.Repaired() {
+		fmt.Printf("Recovered from unclean shutdown: %s\n", report)
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	println("---Starting OneTable console---\n")
 	println("Available commands:")
 	println("get <key>")
+	println("has <key>")
 	println("between <from key> <to key>")
+	println("  inclusive by default, '(a' or 'z)' excludes a key, '*' leaves an end open")
+	println("prefix <prefix>")
 	println("insert <key> <value>")
-	println("delete <key>\n")
+	println("delete <key>")
+	println("stats\n")
 
 	for {
 		input, err := reader.ReadString('\n')
@@ -62,6 +81,13 @@ func main() {
 		inputArr := strings.Split(strings.Trim(input, "\n"), " ")
 
 		command := inputArr[0]
+		if command == "stats" {
+			s := t.Stats()
+			fmt.Printf(">keys: %d, data: %d bytes, live: %d bytes, tombstones: %d, index: %s, loaded in %s\n",
+				s.Keys, s.DataBytes, s.LiveBytes, s.Tombstones, s.IndexType, s.LoadDuration)
+			continue
+		}
+
 		if len(inputArr) < 2 {
 			log.Println("Invalid input")
 			continue
@@ -70,18 +96,21 @@ func main() {
 
 		if command == "get" {
 			value, err := t.Get(key)
-			if err != nil {
+			if errors.Is(err, onetable.ErrNotFound) {
+				fmt.Printf(">Key '%s' not found\n", key)
+			} else if err != nil {
 				fmt.Printf("ERROR: %s\n", err.Error())
 			} else {
-				if value == nil {
-					fmt.Printf(">Key '%s' not found\n", key)
-					continue
-				}
 				fmt.Printf(">%s: %s\n", key, string(value))
 			}
 			continue
 		}
 
+		if command == "has" {
+			fmt.Printf(">%s: %t\n", key, t.Has(key))
+			continue
+		}
+
 		if command == "delete" {
 			err := t.Delete(key)
 			if err != nil {
@@ -110,9 +139,10 @@ func main() {
 		if command == "between" {
 			if len(inputArr) != 3 {
 				fmt.Println("Invalid between instruction. Expected 'between <from key> <to key>'")
+				continue
 			}
 
-			items, err := t.Between(inputArr[1], inputArr[2])
+			items, err := t.BetweenRange(parseRange(inputArr[1], inputArr[2]))
 
 			if err != nil {
 				fmt.Println(err.Error())
@@ -126,6 +156,45 @@ func main() {
 			fmt.Println(">" + res)
 			continue
 		}
+		if command == "prefix" {
+			c := t.Prefix(key)
+
+			res := ""
+			for k, v := range c.All() {
+				res += fmt.Sprintf("%s: %s\t", k, v)
+			}
+
+			if c.Err() != nil {
+				fmt.Println(c.Err().Error())
+				continue
+			}
+			fmt.Println(">" + res)
+			continue
+		}
 		println("Invalid instruction")
 	}
 }
+
+// parseRange reads the bounds of a between command. '[a' and 'z]' are
+// inclusive like plain keys, '(a' and 'z)' exclusive and '*' unbounded.
+func parseRange(from string, to string) onetable.Range {
+	var r onetable.Range
+
+	switch {
+	case from == "*":
+	case strings.HasPrefix(from, "("):
+		r.Start = onetable.Excl(from[1:])
+	default:
+		r.Start = onetable.Incl(strings.TrimPrefix(from, "["))
+	}
+
+	switch {
+	case to == "*":
+	case strings.HasSuffix(to, ")"):
+		r.End = onetable.Excl(to[:len(to)-1])
+	default:
+		r.End = onetable.Incl(strings.TrimSuffix(to, "]"))
+	}
+
+	return r
+}
@@ -0,0 +1,243 @@
+package onetable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+)
+
+const compactSuffix string = ".compact"
+
+// ErrHalfSwapped is returned by writes after Compact failed between swapping
+// the data file and the index file. The open files no longer match the ones
+// on disk, so the table takes no more writes. Reopening it finishes the swap.
+var ErrHalfSwapped = errors.New("Compact left the files half swapped, reopen the table")
+
+// Compact rewrites the data and index files so that they contain only the
+// values that are live in the Index. Overwritten values and tombstones are
+// dropped and the offsets in the Index are updated to point into the new
+// data file.
+//
+// Inserts and deletes are blocked while Compact runs. Get and Between keep
+// being served from the old files until the new ones are swapped in.
+// If Compact fails after swapping in the new data file, writes return
+// ErrHalfSwapped until the table is reopened.
+func (o *OneTable) Compact() error {
+	return o.compact(o.format)
+}
+
+// Upgrade rewrites a table in the legacy CSV format, or in the first version
+// of the binary format, into the current binary format. It compacts the
+// table on the way. Tables already in the current format are left as they
+// are.
+func (o *OneTable) Upgrade() error {
+	if o.format == formatBinary {
+		return nil
+	}
+
+	return o.compact(formatBinary)
+}
+
+// Migrate upgrades the table in folderPath to the binary format, see Upgrade
+func Migrate(folderPath string) error {
+	table, err := New(folderPath, NewIndexHashTable())
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(table.Upgrade(), table.Close())
+}
+
+// compact rewrites the table into files of the given format. It waits for
+// open transactions to finish.
+func (o *OneTable) compact(format fileFormat) error {
+	o.txLock.Lock()
+	defer o.txLock.Unlock()
+
+	o.snapshotLock.Lock()
+	defer o.snapshotLock.Unlock()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.halfSwapped != nil {
+		return o.halfSwapped
+	}
+
+	var items []*Item
+	err := o.Index.Ascend(func(it *Item) bool {
+		items = append(items, it)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	compactDataPath := o.dataPath + compactSuffix
+	compactIndexPath := o.indexPath + compactSuffix
+
+	metas, offset, err := writeCompacted(o.dataFile, format, o.version, items, compactDataPath, compactIndexPath)
+	if err != nil {
+		os.Remove(compactDataPath)
+		os.Remove(compactIndexPath)
+		return err
+	}
+
+	// the snapshot covers positions in the old index file
+	hintPath := path.Join(o.Path, hintFileName)
+	if err := os.Remove(hintPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	o.fileLock.Lock()
+	defer o.fileLock.Unlock()
+
+	// The data file is swapped first. If we crash before the index file
+	// is swapped as well, loadData finishes the job (see recoverCompaction).
+	// The folder is synced after each swap, so that the index swap cannot
+	// reach the disk without the data swap.
+	if err := os.Rename(compactDataPath, o.dataPath); err != nil {
+		os.Remove(compactDataPath)
+		os.Remove(compactIndexPath)
+		return err
+	}
+
+	// From here on the old files are gone from the folder, writing on
+	// through the open handles would lose the writes on reopen
+	if err := syncDir(o.Path); err != nil {
+		return o.stopWrites(err)
+	}
+
+	if err := os.Rename(compactIndexPath, o.indexPath); err != nil {
+		return o.stopWrites(err)
+	}
+
+	if err := syncDir(o.Path); err != nil {
+		return o.stopWrites(err)
+	}
+
+	o.compactions++
+	o.unmap()
+	o.closeFiles()
+	if err := o.openFiles(); err != nil {
+		return o.stopWrites(err)
+	}
+
+	for i, it := range items {
+		o.Index.Insert(it.Key, metas[i])
+	}
+
+	o.format = format
+	o.offset = offset
+	o.records = len(items)
+	o.tombstones = 0
+
+	return nil
+}
+
+// stopWrites makes all further writes fail after Compact could not finish
+// swapping the files. It is called with lock held.
+func (o *OneTable) stopWrites(err error) error {
+	o.halfSwapped = fmt.Errorf("%w: %w", ErrHalfSwapped, err)
+	return o.halfSwapped
+}
+
+// writeCompacted copies the values of items from src into a new data file and
+// writes a matching index file in the given format, whose key versions start
+// above base. Values written before
+// checksums were introduced get one on the way. It returns the new metadata
+// of every item and the size of the new data file.
+func writeCompacted(src *os.File, format fileFormat, base uint64, items []*Item, dataPath string, indexPath string) ([]valueMetadata, typeOffset, error) {
+	dataFile, err := os.OpenFile(dataPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer dataFile.Close()
+
+	indexFile, err := os.OpenFile(indexPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer indexFile.Close()
+
+	w := bufio.NewWriter(indexFile)
+	metas := make([]valueMetadata, len(items))
+	offset := typeOffset(format.dataStart())
+
+	if format.binary() {
+		if _, err := dataFile.Write(format.header(dataMagic, 0)); err != nil {
+			return nil, 0, err
+		}
+		// the tombstones are gone, the header keeps the versions they used
+		w.Write(format.header(indexMagic, base))
+	}
+
+	for i, it := range items {
+		valueMeta := toValueMetadata(it.Value)
+		value := io.NewSectionReader(src, int64(valueMeta.offset), int64(valueMeta.length))
+		crc := crc32.New(castagnoli)
+
+		if _, err := io.Copy(io.MultiWriter(dataFile, crc), value); err != nil {
+			return nil, 0, err
+		}
+
+		if !valueMeta.checksummed {
+			valueMeta.checksum = crc.Sum32()
+			valueMeta.checksummed = true
+		}
+
+		valueMeta.offset = offset
+		w.Write(format.appendRecord(nil, indexRecord{key: it.Key, valueMeta: valueMeta}))
+
+		metas[i] = valueMeta
+		offset += typeOffset(valueMeta.length)
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := dataFile.Sync(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := indexFile.Sync(); err != nil {
+		return nil, 0, err
+	}
+
+	return metas, offset, nil
+}
+
+// recoverCompaction cleans up after a Compact that was interrupted by a
+// crash. If the compacted data file is still present the old files are
+// intact and the leftovers are removed. If only the compacted index file is
+// left, the data file was already swapped and the index must follow.
+func recoverCompaction(dataPath string, indexPath string) error {
+	compactDataPath := dataPath + compactSuffix
+	compactIndexPath := indexPath + compactSuffix
+
+	folderPath := path.Dir(indexPath)
+
+	if _, err := os.Stat(compactIndexPath); os.IsNotExist(err) {
+		os.Remove(compactDataPath)
+		return nil
+	}
+
+	if _, err := os.Stat(compactDataPath); err == nil {
+		os.Remove(compactDataPath)
+		if err := os.Remove(compactIndexPath); err != nil {
+			return err
+		}
+		return syncDir(folderPath)
+	}
+
+	if err := os.Rename(compactIndexPath, indexPath); err != nil {
+		return err
+	}
+
+	return syncDir(folderPath)
+}
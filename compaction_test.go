@@ -0,0 +1,230 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key%d", i)
+		for _, v := range []string{"old", "older", "value"} {
+			if err := table.Insert(key, []byte(v+key)); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := table.Delete(fmt.Sprintf("key%d", i)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	before, _ := os.Stat(path.Join(folder, dataFileName))
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	after, _ := os.Stat(path.Join(folder, dataFileName))
+	if after.Size() >= before.Size() {
+		t.Fatalf("Data file did not shrink. Before %d, After %d", before.Size(), after.Size())
+	}
+
+	check := func(table *OneTable) {
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key%d", i)
+			v, err := table.Get(key)
+			if i < 5 {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("Deleted key %s found after compaction", key)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			if i >= 5 && string(v) != "value"+key {
+				t.Fatalf("Expected %s, Got %s", "value"+key, v)
+			}
+		}
+	}
+
+	check(table)
+
+	if err := table.Insert("key0", []byte("new")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, _ := reopened.Get("key0")
+	if string(v) != "new" {
+		t.Fatalf("Expected new, Got %s", v)
+	}
+
+	reopened.Delete("key0")
+	check(reopened)
+}
+
+func TestCompactConcurrentReads(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		table.Insert("garbage", []byte("garbage"))
+		table.Insert(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			v, err := table.Get("key42")
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+
+			if string(v) != "value42" {
+				t.Errorf("Expected value42, Got %s", v)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		if err := table.Compact(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestRecoverCompaction(t *testing.T) {
+	folder := t.TempDir()
+	dataPath := path.Join(folder, dataFileName)
+	indexPath := path.Join(folder, indexFileName)
+
+	os.WriteFile(dataPath, []byte("compacted"), 0644)
+	os.WriteFile(indexPath, []byte("a,0,3\na,3,9\n"), 0644)
+	os.WriteFile(indexPath+compactSuffix, []byte("a,0,9\n"), 0644)
+
+	// data file was already swapped, the index has to follow
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, _ := table.Get("a")
+	if string(v) != "compacted" {
+		t.Fatalf("Expected compacted, Got %s", v)
+	}
+
+	if _, err := os.Stat(indexPath + compactSuffix); !os.IsNotExist(err) {
+		t.Fatal("Compacted index file was not renamed")
+	}
+
+	// compaction did not finish, leftovers must be discarded
+	os.WriteFile(dataPath+compactSuffix, []byte("partial"), 0644)
+	os.WriteFile(indexPath+compactSuffix, []byte("a,0,7\n"), 0644)
+
+	table, err = New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, _ = table.Get("a")
+	if string(v) != "compacted" {
+		t.Fatalf("Expected compacted, Got %s", v)
+	}
+
+	for _, p := range []string{dataPath + compactSuffix, indexPath + compactSuffix} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", p)
+		}
+	}
+}
+
+func TestCompactHalfSwapped(t *testing.T) {
+	folder := t.TempDir()
+	indexPath := path.Join(folder, indexFileName)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+	table.Delete("b")
+
+	// a folder in place of the index file makes its swap fail
+	os.Remove(indexPath)
+	os.Mkdir(indexPath, 0755)
+	os.WriteFile(path.Join(indexPath, "x"), nil, 0644)
+
+	if err := table.Compact(); !errors.Is(err, ErrHalfSwapped) {
+		t.Fatalf("Expected ErrHalfSwapped, Got %v", err)
+	}
+
+	var batch Batch
+	batch.Put("c", []byte("val c"))
+
+	for _, err := range []error{table.Insert("c", []byte("val c")), table.Delete("a"), table.Write(&batch), table.Compact(), table.Snapshot()} {
+		if !errors.Is(err, ErrHalfSwapped) {
+			t.Fatalf("Expected ErrHalfSwapped, Got %v", err)
+		}
+	}
+
+	if v, err := table.Get("a"); string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s (%v)", v, err)
+	}
+
+	os.RemoveAll(indexPath)
+
+	// reopening finishes the swap
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v, err := reopened.Get("a"); string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s (%v)", v, err)
+	}
+
+	if reopened.Has("b") || reopened.Has("c") {
+		t.Fatal("Found a key that should not exist")
+	}
+
+	expectStats(t, reopened, 1, 5, 0)
+}
@@ -0,0 +1,25 @@
+package onetable_test
+
+import (
+	"testing"
+
+	"github.com/tsladecek/onetable"
+	"github.com/tsladecek/onetable/indextest"
+)
+
+func TestIndexConformance(t *testing.T) {
+	indexes := map[string]func() onetable.Index{
+		"Hashtable": func() onetable.Index { return onetable.NewIndexHashTable() },
+		"BST":       func() onetable.Index { return onetable.NewIndexBST() },
+		"AVL":       func() onetable.Index { return onetable.NewIndexAVL() },
+		"BTree":     func() onetable.Index { return onetable.NewIndexBTree(2) },
+		"SkipList":  func() onetable.Index { return onetable.NewIndexSkipList() },
+		"ART":       func() onetable.Index { return onetable.NewIndexART() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			indextest.Run(t, newIndex)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package onetable
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// cursorChunk is the number of keys a Cursor fetches from a RangeIndex at
+// once
+const cursorChunk = 64
+
+// Cursor iterates over a key range in key order, reading each value only
+// when it is reached. It holds no locks between calls to Next, so the table
+// can be written to while iterating. Writes behind the cursor are not seen,
+// writes ahead of it may or may not be.
+//
+//	c := t.Iter("a", "z")
+//	for c.Next() {
+//		fmt.Println(c.Key(), c.Value())
+//	}
+//	if err := c.Err(); err != nil {
+//		...
+//	}
+type Cursor struct {
+	table   *OneTable
+	fromKey string
+	toKey   string
+	reverse bool
+	// byPrefix replaces the range with the keys starting with prefix
+	byPrefix bool
+	prefix   string
+	// passed tells that the cursor already visited toKey when going in
+	// reverse, in which case it is skipped
+	passed bool
+	// items is the metadata of the keys fetched ahead, from pos on. It is
+	// valid only while compactions is unchanged.
+	items       []*Item
+	pos         int
+	complete    bool
+	compactions uint64
+	key         string
+	value       []byte
+	err         error
+}
+
+// Iter returns a cursor over the keys from fromKey to toKey inclusive
+func (o *OneTable) Iter(fromKey string, toKey string) *Cursor {
+	return &Cursor{table: o, fromKey: fromKey, toKey: toKey}
+}
+
+// IterReverse returns a cursor over the keys from toKey down to fromKey
+// inclusive, for example the latest entries of timestamp keys
+func (o *OneTable) IterReverse(fromKey string, toKey string) *Cursor {
+	return &Cursor{table: o, fromKey: fromKey, toKey: toKey, reverse: true}
+}
+
+// Prefix returns a cursor over the keys starting with prefix, in key order.
+// Indexes implementing PrefixIndex only visit the matching keys, others are
+// scanned in full.
+func (o *OneTable) Prefix(prefix string) *Cursor {
+	return &Cursor{table: o, fromKey: prefix, byPrefix: true, prefix: prefix}
+}
+
+// Next advances to the next key and reads its value. It returns false at
+// the end of the range or on an error, see Err.
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	o := c.table
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	// Compact moved the values, fetch their new metadata
+	if c.compactions != o.compactions {
+		c.items, c.pos, c.complete = nil, 0, false
+		c.compactions = o.compactions
+	}
+
+	if c.pos == len(c.items) {
+		if c.complete {
+			return false
+		}
+
+		if c.byPrefix {
+			c.items, c.complete, c.err = o.fetchPrefix(c.prefix, c.fromKey, cursorChunk)
+		} else {
+			c.items, c.complete, c.err = o.fetchRange(c.fromKey, c.toKey, cursorChunk, c.reverse)
+		}
+		c.pos = 0
+
+		if c.passed && len(c.items) > 0 && c.items[0].Key == c.toKey {
+			c.pos = 1
+		}
+
+		if c.err != nil || c.pos == len(c.items) {
+			return false
+		}
+	}
+
+	it := c.items[c.pos]
+	c.pos++
+
+	value, err := o.readValue(it.Key, it.Value)
+	if err != nil {
+		c.err = err
+		return false
+	}
+
+	c.key, c.value = it.Key, value
+
+	if c.reverse {
+		c.toKey, c.passed = it.Key, true
+	} else {
+		// the smallest key after it
+		c.fromKey = it.Key + "\x00"
+	}
+
+	return true
+}
+
+// Key returns the key the cursor is at
+func (c *Cursor) Key() string {
+	return c.key
+}
+
+// Value returns the value of the key the cursor is at
+func (c *Cursor) Value() []byte {
+	return c.value
+}
+
+// Err returns the error that stopped the cursor, if any
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// All returns the remaining keys and values as an iterator for range loops.
+// Breaking out of the loop stops the cursor early. Check Err after the loop.
+func (c *Cursor) All() iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		for c.Next() {
+			if !yield(c.key, c.value) {
+				return
+			}
+		}
+	}
+}
+
+// fetchRange returns the metadata of up to limit keys from fromKey to toKey,
+// in reverse order if asked, and whether that is all of them. Indexes that
+// are not a RangeIndex return the whole range at once. It must be called
+// with fileLock held.
+func (o *OneTable) fetchRange(fromKey string, toKey string, limit int, reverse bool) ([]*Item, bool, error) {
+	ri, ok := o.Index.(RangeIndex)
+	if !ok {
+		items, err := o.Index.Between(fromKey, toKey)
+		if reverse {
+			slices.Reverse(items)
+		}
+		return items, true, err
+	}
+
+	var items []*Item
+	collect := func(it *Item) bool {
+		items = append(items, it)
+		return len(items) < limit
+	}
+
+	var err error
+	if reverse {
+		err = ri.DescendRange(fromKey, toKey, collect)
+	} else {
+		err = ri.AscendRange(fromKey, toKey, collect)
+	}
+
+	return items, len(items) < limit, err
+}
+
+// fetchPrefix returns the metadata of up to limit keys starting with prefix
+// from fromKey on, and whether that is all of them. Without a PrefixIndex the
+// whole Index is scanned and all matching keys are returned at once. It must
+// be called with fileLock held.
+func (o *OneTable) fetchPrefix(prefix string, fromKey string, limit int) ([]*Item, bool, error) {
+	var items []*Item
+
+	if pi, ok := o.Index.(PrefixIndex); ok {
+		err := pi.AscendPrefix(prefix, fromKey, func(it *Item) bool {
+			items = append(items, it)
+			return len(items) < limit
+		})
+
+		if !errors.Is(err, ErrFullScan) {
+			return items, len(items) < limit, err
+		}
+	}
+
+	err := o.Index.Ascend(func(it *Item) bool {
+		if it.Key >= fromKey && strings.HasPrefix(it.Key, prefix) {
+			items = append(items, it)
+		}
+		return true
+	})
+
+	return items, true, err
+}
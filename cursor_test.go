@@ -0,0 +1,262 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	indexes := map[string]func() Index{
+		"Hashtable": func() Index { return NewIndexHashTable() },
+		"BTree":     func() Index { return NewIndexBTree(2) },
+		"ART":       func() Index { return NewIndexART() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i := 0; i < 300; i++ {
+				table.Insert(fmt.Sprintf("key%03d", i), []byte(fmt.Sprintf("value%d", i)))
+			}
+
+			c := table.Iter("key010", "key209")
+			i := 10
+			for c.Next() {
+				if c.Key() != fmt.Sprintf("key%03d", i) || string(c.Value()) != fmt.Sprintf("value%d", i) {
+					t.Fatalf("Expected key%03d: value%d, Got %s: %s", i, i, c.Key(), c.Value())
+				}
+				i++
+			}
+
+			if c.Err() != nil {
+				t.Fatal(c.Err().Error())
+			}
+
+			if i != 210 {
+				t.Fatalf("Expected to end at key210, Got key%03d", i)
+			}
+		})
+	}
+}
+
+func TestCursorStopEarly(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexSkipList())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		table.Insert(fmt.Sprintf("key%03d", i), []byte("value"))
+	}
+
+	c := table.Iter("", "\xff")
+	var keys []string
+	for key := range c.All() {
+		keys = append(keys, key)
+		if len(keys) == 3 {
+			break
+		}
+	}
+
+	if len(keys) != 3 || keys[2] != "key002" {
+		t.Fatalf("Unexpected keys %v", keys)
+	}
+
+	// the cursor continues where the loop stopped
+	if !c.Next() || c.Key() != "key003" {
+		t.Fatalf("Expected key003, Got %s", c.Key())
+	}
+}
+
+func TestCursorConcurrentWrites(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexAVL())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 200; i++ {
+		table.Insert(fmt.Sprintf("key%03d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	c := table.Iter("", "\xff")
+	count := 0
+	for c.Next() {
+		// overwrite and delete keys ahead of the cursor, and compact the
+		// files underneath it
+		if count == 50 {
+			for i := 100; i < 200; i++ {
+				table.Insert(fmt.Sprintf("key%03d", i), []byte("new"))
+			}
+			table.Delete("key150")
+			if err := table.Compact(); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		if count > 100 && string(c.Value()) != "new" {
+			t.Fatalf("Expected new value of %s, Got %s", c.Key(), c.Value())
+		}
+
+		if c.Key() == "key150" {
+			t.Fatal("Deleted key visited")
+		}
+		count++
+	}
+
+	if c.Err() != nil {
+		t.Fatal(c.Err().Error())
+	}
+
+	if count != 199 {
+		t.Fatalf("Expected 199 keys, Got %d", count)
+	}
+}
+
+func TestCursorError(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+
+	f, _ := os.OpenFile(path.Join(folder, dataFileName), os.O_WRONLY, 0644)
+	f.WriteAt([]byte("X"), headerSize+7)
+	f.Close()
+
+	c := table.Iter("a", "b")
+	if !c.Next() || c.Key() != "a" {
+		t.Fatal("Expected key a")
+	}
+
+	if c.Next() {
+		t.Fatal("Cursor advanced over a corrupted value")
+	}
+
+	if !errors.Is(c.Err(), ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted, Got %v", c.Err())
+	}
+}
+
+func TestCursorReverse(t *testing.T) {
+	indexes := map[string]func() Index{
+		"Hashtable": func() Index { return NewIndexHashTable() },
+		"BST":       func() Index { return NewIndexBST() },
+		"AVL":       func() Index { return NewIndexAVL() },
+		"BTree":     func() Index { return NewIndexBTree(2) },
+		"SkipList":  func() Index { return NewIndexSkipList() },
+		"ART":       func() Index { return NewIndexART() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i := 0; i < 300; i++ {
+				table.Insert(fmt.Sprintf("ts%05d", i*10), []byte(fmt.Sprintf("event%d", i)))
+			}
+
+			c := table.IterReverse("ts00100", "ts02095")
+			i := 209
+			for c.Next() {
+				if c.Key() != fmt.Sprintf("ts%05d", i*10) || string(c.Value()) != fmt.Sprintf("event%d", i) {
+					t.Fatalf("Expected ts%05d: event%d, Got %s: %s", i*10, i, c.Key(), c.Value())
+				}
+				i--
+			}
+
+			if c.Err() != nil {
+				t.Fatal(c.Err().Error())
+			}
+
+			if i != 9 {
+				t.Fatalf("Expected to end at ts00090, Got ts%05d", i*10)
+			}
+
+			// latest 3 events
+			var latest []string
+			for key := range table.IterReverse("", "\xff").All() {
+				latest = append(latest, key)
+				if len(latest) == 3 {
+					break
+				}
+			}
+
+			if len(latest) != 3 || latest[0] != "ts02990" || latest[2] != "ts02970" {
+				t.Fatalf("Unexpected latest keys %v", latest)
+			}
+		})
+	}
+}
+
+func TestCursorPrefix(t *testing.T) {
+	indexes := map[string]func() Index{
+		"Hashtable": func() Index { return NewIndexHashTable() },
+		"BST":       func() Index { return NewIndexBST() },
+		"SkipList":  func() Index { return NewIndexSkipList() },
+		"ART":       func() Index { return NewIndexART() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i := 0; i < 100; i++ {
+				table.Insert(fmt.Sprintf("user/%d/item%03d", i%3, i), []byte(fmt.Sprintf("value%d", i)))
+			}
+			// Between("user/1/", "user/1/\xff") misses this key
+			table.Insert("user/1/\xff\xff", []byte("last"))
+			table.Insert("user/1", []byte("not a child"))
+
+			c := table.Prefix("user/1/")
+			i := 1
+			for c.Next() {
+				if i >= 100 {
+					if c.Key() != "user/1/\xff\xff" || string(c.Value()) != "last" {
+						t.Fatalf("Expected user/1/\\xff\\xff: last, Got %q: %s", c.Key(), c.Value())
+					}
+					i = -1
+					continue
+				}
+
+				expected := fmt.Sprintf("user/1/item%03d", i)
+				if c.Key() != expected || string(c.Value()) != fmt.Sprintf("value%d", i) {
+					t.Fatalf("Expected %s: value%d, Got %s: %s", expected, i, c.Key(), c.Value())
+				}
+				i += 3
+			}
+
+			if c.Err() != nil {
+				t.Fatal(c.Err().Error())
+			}
+
+			if i != -1 {
+				t.Fatal("Prefix did not reach the last key")
+			}
+
+			n := 0
+			for range table.Prefix("").All() {
+				n++
+			}
+
+			if n != 102 {
+				t.Fatalf("Expected 102 keys for the empty prefix, Got %d", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package onetable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestNewFolderMissing(t *testing.T) {
+	folder := path.Join(t.TempDir(), "nested", "table")
+
+	if _, err := New(folder, NewIndexHashTable()); !errors.Is(err, ErrFolderMissing) {
+		t.Fatalf("Expected ErrFolderMissing, Got %v", err)
+	}
+
+	table, err := NewWithOptions(folder, NewIndexHashTable(), Options{CreateFolder: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("a", []byte("val a")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestNewIndexMissing(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(path.Join(folder, dataFileName), []byte("val a"), 0644)
+
+	if _, err := New(folder, NewIndexHashTable()); !errors.Is(err, ErrIndexMissing) {
+		t.Fatalf("Expected ErrIndexMissing, Got %v", err)
+	}
+}
+
+func TestNewCorruptLegacyIndex(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(path.Join(folder, dataFileName), []byte("val aval b"), 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte("a,0,5\nb,x,5\n"), 0644)
+
+	_, err := New(folder, NewIndexHashTable())
+
+	var corrupt *CorruptIndexError
+	if !errors.Is(err, ErrCorruptIndex) || !errors.As(err, &corrupt) || corrupt.Line != 2 {
+		t.Fatalf("Expected corrupt index at line 2, Got %v", err)
+	}
+}
+
+func TestNewNegativeLegacyIndex(t *testing.T) {
+	for _, line := range []string{"b,0,-3\n", "b,-5,5\n"} {
+		folder := t.TempDir()
+		os.WriteFile(path.Join(folder, dataFileName), []byte("val aval b"), 0644)
+		os.WriteFile(path.Join(folder, indexFileName), []byte("a,0,5\nc,-1,-1\n"+line), 0644)
+
+		_, err := NewWithOptions(folder, NewIndexHashTable(), Options{Mmap: true})
+
+		var corrupt *CorruptIndexError
+		if !errors.Is(err, ErrCorruptIndex) || !errors.As(err, &corrupt) || corrupt.Line != 3 {
+			t.Fatalf("Expected corrupt index at line 3 for %q, Got %v", line, err)
+		}
+	}
+}
+
+func TestNewCorruptBinaryIndex(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+	table.Insert("c", []byte("val c"))
+	table.Close()
+
+	// flip a byte of the key of the second record, the third one is intact
+	indexPath := path.Join(folder, indexFileName)
+	index := mustRead(t, indexPath)
+	recordSize := (len(index) - int(headerSize)) / 3
+	index[int(headerSize)+recordSize+10] ^= 0xff
+	os.WriteFile(indexPath, index, 0644)
+
+	_, err = New(folder, NewIndexHashTable())
+
+	var corrupt *CorruptIndexError
+	if !errors.Is(err, ErrCorruptIndex) || !errors.As(err, &corrupt) || corrupt.Line != 2 {
+		t.Fatalf("Expected corrupt index at record 2, Got %v", err)
+	}
+}
+
+func TestNewCorruptRecordLength(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		table.Insert(fmt.Sprintf("key%03d", i), []byte("val"))
+	}
+	table.Close()
+
+	indexPath := path.Join(folder, indexFileName)
+	index := mustRead(t, indexPath)
+
+	// the first records have the same size, a prefix, the body and its crc
+	recordSize := 8 + int(binary.LittleEndian.Uint32(index[headerSize:])) + 4
+
+	// a length pointing past the end of the file is not taken for a torn
+	// write when the length does not match its checksum
+	index[int(headerSize)+2*recordSize+2] ^= 0x01
+	os.WriteFile(indexPath, index, 0644)
+
+	_, err = New(folder, NewIndexHashTable())
+
+	var corrupt *CorruptIndexError
+	if !errors.As(err, &corrupt) || corrupt.Line != 3 {
+		t.Fatalf("Expected corrupt index at record 3, Got %v", err)
+	}
+
+	if info, _ := os.Stat(indexPath); info.Size() != int64(len(index)) {
+		t.Fatalf("Index file truncated to %d bytes", info.Size())
+	}
+}
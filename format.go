@@ -0,0 +1,552 @@
+package onetable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fileFormat is the on-disk layout of the data and index files.
+//
+// formatLegacyCSV is the original layout. The data file holds the raw
+// values and the index file holds CSV records
+// {key},{offset},{length}[,{checksum}] with length -1 marking a tombstone.
+// Key versions are not stored and are assigned anew on every load.
+//
+// formatBinary starts both files with a header
+//
+//	magic   [4]byte "OTDT" for the data file, "OTIX" for the index file
+//	version uint16 2
+//	flags   uint16
+//	base    uint64 key versions of the index file start above base,
+//	               unused in the data file
+//
+// The data file continues with the raw values, whose offsets count from the
+// start of the file. The index file continues with length-prefixed records,
+// integers are little endian:
+//
+//	length   uint32 length of the body
+//	lcrc     uint32 CRC32C of length
+//	body     op byte, key length uvarint, key, offset varint,
+//	         value length uvarint, checksum uint32, key version uvarint
+//	crc      uint32 CRC32C of the body
+//
+// A record whose length checks out but whose body is cut short by the end of
+// the file is a torn write. A length that does not check out is damage, and
+// so is a body that does not match its crc, unless it ends the file.
+//
+// formatBinaryV1 is the same with version 1 in the header. It predates key
+// versions, so its puts and deletes use their own ops and end with the
+// checksum, and base is unused. Versions are assigned anew on every load,
+// like in the legacy format, so the version based operations return
+// ErrLegacyFormat on both. Its records have no lcrc either, so a damaged
+// length is taken for a torn write. Upgrade rewrites it into formatBinary.
+//
+// The body of a batch record is the batch op, the number of operations as
+// uvarint and the operations, each laid out like the body of a put or a
+// delete. A batch is a single record, so it is replayed whole or not at all.
+//
+// New tables are created in formatBinary. Legacy tables keep being written
+// as CSV until they are upgraded with Upgrade or Migrate.
+type fileFormat int
+
+const (
+	formatLegacyCSV fileFormat = iota
+	formatBinary
+	formatBinaryV1
+)
+
+const (
+	headerSize      int64  = 16
+	dataMagic       string = "OTDT"
+	indexMagic      string = "OTIX"
+	formatVersion   uint16 = 2
+	formatVersionV1 uint16 = 1
+)
+
+const (
+	opPutUnversioned    byte = 1
+	opDeleteUnversioned byte = 2
+	opBatch             byte = 3
+	opPut               byte = 4
+	opDelete            byte = 5
+)
+
+// maxRecordSize bounds the length prefix of a binary record, so that a
+// damaged prefix does not make the reader allocate gigabytes
+const maxRecordSize uint32 = 1 << 30
+
+var ErrUnsupportedFormat = errors.New("Unsupported file format version")
+
+var ErrInvalidKey = errors.New("Invalid key")
+
+// ErrLegacyFormat is returned by operations the legacy csv format, or for
+// key versions also version 1 of the binary format, cannot support. Upgrade
+// the table to use them.
+var ErrLegacyFormat = errors.New("Not supported by the legacy file format, upgrade the table first")
+
+// ErrCorruptIndex is returned (wrapped in a CorruptIndexError) by New when a
+// record in the middle of the index file cannot be read
+var ErrCorruptIndex = errors.New("Index is corrupted")
+
+// CorruptIndexError names the record of the index file that cannot be read.
+// Line counts lines in the legacy csv format and records in the binary one.
+// It matches ErrCorruptIndex with errors.Is.
+type CorruptIndexError struct {
+	Line   int
+	Reason string
+}
+
+func (e *CorruptIndexError) Error() string {
+	return fmt.Sprintf("Invalid record at line %d. %s", e.Line, e.Reason)
+}
+
+func (e *CorruptIndexError) Is(target error) bool {
+	return target == ErrCorruptIndex
+}
+
+type indexRecord struct {
+	key       string
+	valueMeta valueMetadata
+	tombstone bool
+	// batch holds the operations of a batch record, in which case the
+	// other fields are unused
+	batch []indexRecord
+}
+
+// entries returns the puts and deletes carried by the record
+func (r indexRecord) entries() []indexRecord {
+	if r.batch != nil {
+		return r.batch
+	}
+	return []indexRecord{r}
+}
+
+// header returns the header of a file of the format, which must be a binary
+// one. base is dropped by formatBinaryV1.
+func (f fileFormat) header(magic string, base uint64) []byte {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+
+	if f == formatBinaryV1 {
+		binary.LittleEndian.PutUint16(header[4:], formatVersionV1)
+		return header
+	}
+
+	binary.LittleEndian.PutUint16(header[4:], formatVersion)
+	binary.LittleEndian.PutUint64(header[8:], base)
+	return header
+}
+
+// readBaseVersion returns the version the key versions of a binary index
+// file start above
+func readBaseVersion(indexPath string) (uint64, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var base [8]byte
+	if _, err := f.ReadAt(base[:], 8); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(base[:]), nil
+}
+
+// readHeader returns the format version of the header carrying magic the
+// file starts with, or 0 if it does not start with one. A header with an
+// unknown version is an error.
+func readHeader(filePath string, magic string) (uint16, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0, nil
+	}
+
+	if string(header[:len(magic)]) != magic {
+		return 0, nil
+	}
+
+	version := binary.LittleEndian.Uint16(header[4:])
+	if version != formatVersion && version != formatVersionV1 {
+		return 0, fmt.Errorf("%w %d in %s", ErrUnsupportedFormat, version, filePath)
+	}
+
+	return version, nil
+}
+
+// detectFormat tells the format of a table from its index file. Binary
+// index files always start with a header, so an index file without one
+// belongs to a legacy table.
+func detectFormat(indexPath string) (fileFormat, error) {
+	version, err := readHeader(indexPath, indexMagic)
+
+	switch {
+	case err != nil:
+		return formatLegacyCSV, err
+	case version == formatVersionV1:
+		return formatBinaryV1, nil
+	case version != 0:
+		return formatBinary, nil
+	}
+
+	return formatLegacyCSV, nil
+}
+
+// prefixSize is the size of the part of a binary record before the body
+func (f fileFormat) prefixSize() int {
+	if f == formatBinaryV1 {
+		return 4
+	}
+	return 8
+}
+
+// binary tells whether the files of the format start with a header and hold
+// binary index records
+func (f fileFormat) binary() bool {
+	return f != formatLegacyCSV
+}
+
+// dataStart is the offset of the first value in the data file
+func (f fileFormat) dataStart() int64 {
+	if f.binary() {
+		return headerSize
+	}
+	return 0
+}
+
+// indexStart is the position of the first record in the index file
+func (f fileFormat) indexStart() int64 {
+	if f.binary() {
+		return headerSize
+	}
+	return 0
+}
+
+// validateKey checks that key can be stored in the format. The binary format
+// takes any key. CSV quotes keys with commas and quotes, but a newline or a
+// carriage return inside a key would not survive reading the file back.
+func (f fileFormat) validateKey(key string) error {
+	if f == formatLegacyCSV && strings.ContainsAny(key, "\r\n") {
+		return fmt.Errorf("%w. Legacy csv tables cannot store '\\n' or '\\r' in keys, upgrade the table first", ErrInvalidKey)
+	}
+
+	return nil
+}
+
+// appendRecord encodes rec and appends it to buf
+func (f fileFormat) appendRecord(buf []byte, rec indexRecord) []byte {
+	if f == formatLegacyCSV {
+		valueMeta := rec.valueMeta
+		if rec.tombstone {
+			valueMeta = valueMetadata{offset: -1, length: tombstone}
+		}
+
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		w.Write([]string{
+			rec.key,
+			strconv.FormatInt(int64(valueMeta.offset), 10),
+			strconv.Itoa(valueMeta.length),
+			strconv.FormatUint(uint64(valueMeta.checksum), 10),
+		})
+		w.Flush()
+
+		return append(buf, b.Bytes()...)
+	}
+
+	start := len(buf)
+	buf = append(buf, make([]byte, f.prefixSize())...)
+
+	if rec.batch != nil {
+		buf = append(buf, opBatch)
+		buf = binary.AppendUvarint(buf, uint64(len(rec.batch)))
+		for _, entry := range rec.batch {
+			buf = f.appendEntry(buf, entry)
+		}
+	} else {
+		buf = f.appendEntry(buf, rec)
+	}
+
+	body := buf[start+f.prefixSize():]
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(body)))
+	if f == formatBinary {
+		binary.LittleEndian.PutUint32(buf[start+4:], crc32.Checksum(buf[start:start+4], castagnoli))
+	}
+
+	return binary.LittleEndian.AppendUint32(buf, crc32.Checksum(body, castagnoli))
+}
+
+// appendEntry appends a single put or delete to buf
+func (f fileFormat) appendEntry(buf []byte, rec indexRecord) []byte {
+	versioned := f == formatBinary
+
+	op := opPut
+	switch {
+	case rec.tombstone && versioned:
+		op = opDelete
+	case rec.tombstone:
+		op = opDeleteUnversioned
+	case !versioned:
+		op = opPutUnversioned
+	}
+
+	buf = append(buf, op)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.key)))
+	buf = append(buf, rec.key...)
+	buf = binary.AppendVarint(buf, int64(rec.valueMeta.offset))
+	buf = binary.AppendUvarint(buf, uint64(rec.valueMeta.length))
+	buf = binary.LittleEndian.AppendUint32(buf, rec.valueMeta.checksum)
+
+	if !versioned {
+		return buf
+	}
+
+	return binary.AppendUvarint(buf, rec.valueMeta.version)
+}
+
+// recordReader reads index records one at a time
+type recordReader interface {
+	// next returns the next record and the number of bytes read so far.
+	// It returns io.EOF after the last complete record, a torn record at
+	// the end of the file is not returned.
+	next() (indexRecord, int64, error)
+}
+
+// newRecordReader reads records of the format from r. line is the number
+// of records before r, used in error messages.
+func (f fileFormat) newRecordReader(r io.Reader, line int) recordReader {
+	if f == formatLegacyCSV {
+		cr := csv.NewReader(r)
+		// records written before checksums were introduced have only 3 fields
+		cr.FieldsPerRecord = -1
+		return &csvRecordReader{r: cr, line: line}
+	}
+
+	return &binaryRecordReader{r: bufio.NewReader(r), line: line, format: f}
+}
+
+type csvRecordReader struct {
+	r    *csv.Reader
+	line int
+}
+
+func (c *csvRecordReader) next() (indexRecord, int64, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return indexRecord{}, c.r.InputOffset(), err
+	}
+
+	c.line++
+
+	if err != nil {
+		// line numbers of the csv reader count from where replay started
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			err = parseErr.Err
+		}
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: err.Error()}
+	}
+
+	if len(record) != 3 && len(record) != 4 {
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: "Does not contain 3 or 4 separated fields"}
+	}
+
+	rec := indexRecord{key: record[0]}
+
+	offset, err := strconv.ParseInt(record[1], 10, 64)
+	if err != nil {
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: fmt.Sprintf("Offset %s is not an integer", record[1])}
+	}
+
+	length, err := strconv.Atoi(record[2])
+	if err != nil {
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: fmt.Sprintf("Length %s is not an integer", record[2])}
+	}
+
+	if length == tombstone {
+		rec.tombstone = true
+		return rec, c.r.InputOffset(), nil
+	}
+
+	if length < 0 {
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: fmt.Sprintf("Length %d is negative", length)}
+	}
+
+	if offset < 0 {
+		return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: fmt.Sprintf("Offset %d is negative", offset)}
+	}
+
+	rec.valueMeta = valueMetadata{offset: typeOffset(offset), length: length}
+
+	if len(record) == 4 {
+		checksum, err := strconv.ParseUint(record[3], 10, 32)
+		if err != nil {
+			return indexRecord{}, 0, &CorruptIndexError{Line: c.line, Reason: fmt.Sprintf("Checksum %s is not an unsigned 32 bit integer", record[3])}
+		}
+
+		rec.valueMeta.checksum = uint32(checksum)
+		rec.valueMeta.checksummed = true
+	}
+
+	return rec, c.r.InputOffset(), nil
+}
+
+type binaryRecordReader struct {
+	r      *bufio.Reader
+	offset int64
+	line   int
+	format fileFormat
+}
+
+func (b *binaryRecordReader) next() (indexRecord, int64, error) {
+	prefix := make([]byte, b.format.prefixSize())
+	if _, err := io.ReadFull(b.r, prefix); err != nil {
+		// nothing or a torn length prefix left
+		return indexRecord{}, b.offset, io.EOF
+	}
+
+	b.line++
+
+	if b.format == formatBinary && crc32.Checksum(prefix[:4], castagnoli) != binary.LittleEndian.Uint32(prefix[4:]) {
+		return b.damaged()
+	}
+
+	length := binary.LittleEndian.Uint32(prefix)
+	if length > maxRecordSize {
+		return b.damaged()
+	}
+
+	record := make([]byte, length+4)
+	if _, err := io.ReadFull(b.r, record); err != nil {
+		// the file ends before the record does
+		return indexRecord{}, b.offset, io.EOF
+	}
+
+	body := record[:length]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(record[length:]) {
+		return b.damaged()
+	}
+
+	rec, ok := b.format.decodeRecordBody(body)
+	if !ok {
+		return indexRecord{}, 0, &CorruptIndexError{Line: b.line, Reason: "Malformed record body"}
+	}
+
+	b.offset += int64(len(prefix) + len(record))
+
+	return rec, b.offset, nil
+}
+
+// damaged handles a record failing its checks. At the end of the file it is
+// a torn write, anywhere else the index file is corrupted.
+func (b *binaryRecordReader) damaged() (indexRecord, int64, error) {
+	if _, err := b.r.Peek(1); err == io.EOF {
+		return indexRecord{}, b.offset, io.EOF
+	}
+
+	return indexRecord{}, 0, &CorruptIndexError{Line: b.line, Reason: "Checksum does not match"}
+}
+
+func (f fileFormat) decodeRecordBody(body []byte) (indexRecord, bool) {
+	r := bytes.NewReader(body)
+
+	op, err := r.ReadByte()
+	if err != nil {
+		return indexRecord{}, false
+	}
+
+	if op != opBatch {
+		return f.decodeEntry(r, op)
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(r.Len()) {
+		return indexRecord{}, false
+	}
+
+	rec := indexRecord{batch: make([]indexRecord, count)}
+	for i := range rec.batch {
+		op, err := r.ReadByte()
+		if err != nil {
+			return indexRecord{}, false
+		}
+
+		entry, ok := f.decodeEntry(r, op)
+		if !ok {
+			return indexRecord{}, false
+		}
+		rec.batch[i] = entry
+	}
+
+	return rec, true
+}
+
+// decodeEntry reads a single put or delete following its op byte
+func (f fileFormat) decodeEntry(r *bytes.Reader, op byte) (indexRecord, bool) {
+	versioned := f == formatBinary
+	if versioned && op != opPut && op != opDelete {
+		return indexRecord{}, false
+	}
+
+	if !versioned && op != opPutUnversioned && op != opDeleteUnversioned {
+		return indexRecord{}, false
+	}
+
+	keyLength, err := binary.ReadUvarint(r)
+	if err != nil || keyLength > uint64(r.Len()) {
+		return indexRecord{}, false
+	}
+
+	key := make([]byte, keyLength)
+	r.Read(key)
+
+	offset, err := binary.ReadVarint(r)
+	if err != nil {
+		return indexRecord{}, false
+	}
+
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return indexRecord{}, false
+	}
+
+	var checksum uint32
+	if err := binary.Read(r, binary.LittleEndian, &checksum); err != nil {
+		return indexRecord{}, false
+	}
+
+	var version uint64
+	if versioned {
+		if version, err = binary.ReadUvarint(r); err != nil {
+			return indexRecord{}, false
+		}
+	}
+
+	if op == opDelete || op == opDeleteUnversioned {
+		return indexRecord{key: string(key), tombstone: true, valueMeta: valueMetadata{version: version}}, true
+	}
+
+	return indexRecord{key: string(key), valueMeta: valueMetadata{
+		offset:      typeOffset(offset),
+		length:      int(length),
+		checksum:    checksum,
+		checksummed: true,
+		version:     version,
+	}}, true
+}
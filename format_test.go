@@ -0,0 +1,277 @@
+package onetable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestFormatNewTableIsBinary(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fillTable(t, table, 0, 100)
+
+	if version, err := readHeader(path.Join(folder, dataFileName), dataMagic); version != formatVersion || err != nil {
+		t.Fatalf("Data file does not start with a header (%v)", err)
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatBinary {
+		t.Fatal("Index file is not in the binary format")
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if reopened.Recovery().Repaired() {
+		t.Fatalf("Unexpected repair: %s", reopened.Recovery())
+	}
+
+	compareTables(t, table, reopened)
+}
+
+func TestFormatNewTableInterrupted(t *testing.T) {
+	folder := t.TempDir()
+
+	// a crash while creating the table left the index file and part of
+	// the data file's temporary file
+	os.WriteFile(path.Join(folder, indexFileName), formatBinary.header(indexMagic, 0), 0644)
+	os.WriteFile(path.Join(folder, dataFileName+".tmp"), []byte("OT"), 0644)
+
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("a", []byte("val a")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := os.Stat(path.Join(folder, dataFileName+".tmp")); !os.IsNotExist(err) {
+		t.Fatal("Temporary data file was not removed")
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	compareTables(t, table, reopened)
+}
+
+func TestFormatTornBinaryRecord(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+	table.Close()
+
+	// cut the last index record short
+	indexPath := path.Join(folder, indexFileName)
+	info, _ := os.Stat(indexPath)
+	os.Truncate(indexPath, info.Size()-3)
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report := reopened.Recovery()
+	if report.DataBytesTruncated != 5 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	if v, _ := reopened.Get("a"); string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s", v)
+	}
+
+	if v, _ := reopened.Get("b"); v != nil {
+		t.Fatal("Key b found after its record was truncated")
+	}
+}
+
+func TestFormatUnsupportedVersion(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	indexPath := path.Join(folder, indexFileName)
+	index := mustRead(t, indexPath)
+	binary.LittleEndian.PutUint16(index[4:], formatVersion+1)
+	os.WriteFile(indexPath, index, 0644)
+
+	if _, err := detectFormat(indexPath); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Expected ErrUnsupportedFormat, Got %v", err)
+	}
+}
+
+func TestFormatUpgradeLegacy(t *testing.T) {
+	folder := t.TempDir()
+
+	os.WriteFile(path.Join(folder, dataFileName), []byte("val aval bval c"), 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte("a,0,5\nb,5,5\nc,10,5\nb,-1,-1\n"), 0644)
+
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// legacy tables keep being written as CSV until upgraded
+	if err := table.Insert("d", []byte("val d")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatLegacyCSV {
+		t.Fatal("Legacy table was written in the binary format before an upgrade")
+	}
+
+	if err := table.Upgrade(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatBinary {
+		t.Fatal("Index file is not in the binary format after an upgrade")
+	}
+
+	if err := table.Insert("e", []byte("val e")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range []string{"a", "c", "d", "e"} {
+		if v, _ := reopened.Get(key); string(v) != "val "+key {
+			t.Fatalf("Expected 'val %s', Got %s", key, v)
+		}
+	}
+
+	if v, _ := reopened.Get("b"); v != nil {
+		t.Fatal("Deleted key b found after an upgrade")
+	}
+}
+
+func TestFormatMigrate(t *testing.T) {
+	folder := t.TempDir()
+
+	os.WriteFile(path.Join(folder, dataFileName), []byte("val a"), 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte("a,0,5\n"), 0644)
+
+	if err := Migrate(folder); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatBinary {
+		t.Fatal("Index file is not in the binary format after Migrate")
+	}
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v, _ := table.Get("a"); string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s", v)
+	}
+}
+
+func TestFormatBinaryV1(t *testing.T) {
+	folder := t.TempDir()
+
+	// a table written before key versions were stored
+	data := append(formatBinaryV1.header(dataMagic, 0), "val aval b"...)
+	index := formatBinaryV1.header(indexMagic, 0)
+	index = formatBinaryV1.appendRecord(index, indexRecord{key: "a", valueMeta: valueMetadata{offset: 16, length: 5, checksum: checksum([]byte("val a"))}})
+	index = formatBinaryV1.appendRecord(index, indexRecord{key: "b", valueMeta: valueMetadata{offset: 21, length: 5, checksum: checksum([]byte("val b"))}})
+	index = formatBinaryV1.appendRecord(index, indexRecord{key: "a", tombstone: true})
+	os.WriteFile(path.Join(folder, dataFileName), data, 0644)
+	os.WriteFile(path.Join(folder, indexFileName), index, 0644)
+
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if table.Has("a") {
+		t.Fatal("Deleted key a found")
+	}
+
+	// versions are not stored, so they cannot be compared
+	if _, _, err := table.GetWithVersion("b"); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	if _, err := table.DeleteIfVersion("b", 2); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("Expected ErrLegacyFormat, Got %v", err)
+	}
+
+	// version 1 tables keep being written in their own layout
+	var batch Batch
+	batch.Put("c", []byte("val c"))
+	batch.Delete("b")
+	if err := table.Write(&batch); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatBinaryV1 {
+		t.Fatal("Version 1 table was written in a newer format before an upgrade")
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	compareTables(t, table, reopened)
+
+	if err := reopened.Upgrade(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format, _ := detectFormat(path.Join(folder, indexFileName)); format != formatBinary {
+		t.Fatal("Index file is not in the current format after an upgrade")
+	}
+
+	upgraded, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	compareTables(t, table, upgraded)
+
+	if _, _, err := upgraded.GetWithVersion("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestFormatLargeOffset(t *testing.T) {
+	// offsets past 4 GiB survive every format, also on 32 bit platforms
+	valueMeta := valueMetadata{offset: 5 << 30, length: 3, checksummed: true, version: 1}
+
+	for _, format := range []fileFormat{formatLegacyCSV, formatBinary, formatBinaryV1} {
+		buf := format.appendRecord(nil, indexRecord{key: "a", valueMeta: valueMeta})
+
+		rec, _, err := format.newRecordReader(bytes.NewReader(buf), 0).next()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if rec.valueMeta.Offset() != 5<<30 {
+			t.Fatalf("Expected offset %d, Got %d", int64(5<<30), rec.valueMeta.Offset())
+		}
+	}
+}
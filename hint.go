@@ -0,0 +1,296 @@
+package onetable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+)
+
+// A hint file is a binary snapshot of the Index, similar to Bitcask hint
+// files. It records the position in the index file it covers, so that
+// loading it and replaying only the tail of the index file restores the
+// Index without replaying the whole log. A copy of the bytes right before
+// that position ties the hint to the exact index file it was taken from, so
+// that it is not applied to a log whose tail was lost and rewritten since.
+// A checksum would not do, as the CRC32C of records that end with their own
+// CRC32C is the same whatever their content.
+//
+// Layout, integers are little endian:
+//
+//	magic    [4]byte "OTHT"
+//	version  uint16
+//	indexEnd int64   bytes of the index file covered by the snapshot
+//	dataEnd  int64   end of the last value referenced up to indexEnd
+//	records  uint64  number of index records up to indexEnd
+//	kversion uint64  last key version used up to indexEnd
+//	tombs    uint64  number of tombstones up to indexEnd
+//	tail     uvarint length, then the hintTailSize bytes of the index
+//	                 file before indexEnd, or all of them if there are fewer
+//	count    uint64  number of entries
+//	entries  count * {key length uvarint, key, offset varint,
+//	                  length uvarint, checksummed byte, checksum uint32,
+//	                  key version uvarint}
+//	crc      uint32  CRC32C of everything before it
+const (
+	hintFileName string = "index.hint"
+	hintMagic    string = "OTHT"
+	hintVersion  uint16 = 4
+	hintTailSize int64  = 64
+)
+
+var errInvalidHint = errors.New("Invalid hint file")
+
+// Snapshot writes the current state of the Index to the hint file. Writes
+// are blocked only while the Index is copied, not while the file is written.
+func (o *OneTable) Snapshot() error {
+	o.snapshotLock.Lock()
+	defer o.snapshotLock.Unlock()
+
+	o.lock.Lock()
+
+	if o.halfSwapped != nil {
+		o.lock.Unlock()
+		return o.halfSwapped
+	}
+
+	info, err := o.indexFile.Stat()
+	if err != nil {
+		o.lock.Unlock()
+		return err
+	}
+
+	pos := logPosition{indexEnd: info.Size(), dataEnd: int64(o.offset), records: o.records, version: o.version, tombstones: o.tombstones}
+
+	var items []*Item
+	err = o.Index.Ascend(func(it *Item) bool {
+		items = append(items, it)
+		return true
+	})
+
+	o.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// the hint must not cover writes that can still be lost
+	if err := o.syncFiles(); err != nil {
+		return err
+	}
+
+	tail, err := readIndexTail(o.indexPath, pos.indexEnd)
+	if err != nil {
+		return err
+	}
+
+	return writeHint(path.Join(o.Path, hintFileName), pos, tail, items)
+}
+
+// readIndexTail returns the last hintTailSize bytes of the index file before
+// indexEnd
+func readIndexTail(indexPath string, indexEnd int64) ([]byte, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := make([]byte, min(indexEnd, hintTailSize))
+	if _, err := f.ReadAt(b, indexEnd-int64(len(b))); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func writeHint(hintPath string, pos logPosition, tail []byte, items []*Item) error {
+	tmpPath := hintPath + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer f.Close()
+
+	crc := crc32.New(castagnoli)
+	w := bufio.NewWriter(io.MultiWriter(f, crc))
+
+	w.WriteString(hintMagic)
+	binary.Write(w, binary.LittleEndian, hintVersion)
+	binary.Write(w, binary.LittleEndian, pos.indexEnd)
+	binary.Write(w, binary.LittleEndian, pos.dataEnd)
+	binary.Write(w, binary.LittleEndian, uint64(pos.records))
+	binary.Write(w, binary.LittleEndian, pos.version)
+	binary.Write(w, binary.LittleEndian, uint64(pos.tombstones))
+	w.Write(binary.AppendUvarint(nil, uint64(len(tail))))
+	w.Write(tail)
+	binary.Write(w, binary.LittleEndian, uint64(len(items)))
+
+	for _, it := range items {
+		valueMeta := toValueMetadata(it.Value)
+
+		w.Write(binary.AppendUvarint(nil, uint64(len(it.Key))))
+		w.WriteString(it.Key)
+		w.Write(binary.AppendVarint(nil, int64(valueMeta.offset)))
+		w.Write(binary.AppendUvarint(nil, uint64(valueMeta.length)))
+
+		var checksummed byte
+		if valueMeta.checksummed {
+			checksummed = 1
+		}
+		w.WriteByte(checksummed)
+		binary.Write(w, binary.LittleEndian, valueMeta.checksum)
+		w.Write(binary.AppendUvarint(nil, valueMeta.version))
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := binary.Write(f, binary.LittleEndian, crc.Sum32()); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, hintPath)
+}
+
+// loadHint fills the Index from the hint file and returns the position in
+// the index file to continue replaying from. A missing, damaged or stale
+// hint file is ignored, in which case the whole index file is replayed. An
+// ignored hint is removed, as the files may later grow past it again.
+func (o *OneTable) loadHint(hintPath string, indexPath string, indexSize int64, dataSize int64) (logPosition, error) {
+	b, err := os.ReadFile(hintPath)
+	if os.IsNotExist(err) {
+		return logPosition{}, nil
+	}
+
+	if err != nil {
+		return logPosition{}, err
+	}
+
+	pos, tail, items, err := decodeHint(b)
+	if err == nil && (pos.indexEnd > indexSize || pos.dataEnd > dataSize) {
+		err = errInvalidHint
+	}
+
+	if err == nil {
+		var current []byte
+		current, err = readIndexTail(indexPath, pos.indexEnd)
+		if err == nil && !bytes.Equal(current, tail) {
+			err = errInvalidHint
+		}
+	}
+
+	if err != nil {
+		if err := os.Remove(hintPath); err != nil && !os.IsNotExist(err) {
+			return logPosition{}, err
+		}
+		return logPosition{}, nil
+	}
+
+	for _, it := range items {
+		o.indexInsert(it.Key, it.Value)
+	}
+
+	return pos, nil
+}
+
+func decodeHint(b []byte) (logPosition, []byte, []*Item, error) {
+	headerSize := len(hintMagic) + 2 + 6*8 + 1
+	if len(b) < headerSize+4 {
+		return logPosition{}, nil, nil, errInvalidHint
+	}
+
+	content, sum := b[:len(b)-4], binary.LittleEndian.Uint32(b[len(b)-4:])
+	if crc32.Checksum(content, castagnoli) != sum || string(content[:len(hintMagic)]) != hintMagic {
+		return logPosition{}, nil, nil, errInvalidHint
+	}
+
+	r := bytes.NewReader(content[len(hintMagic):])
+
+	var version uint16
+	var records, tombstones, count uint64
+	var pos logPosition
+
+	binary.Read(r, binary.LittleEndian, &version)
+	binary.Read(r, binary.LittleEndian, &pos.indexEnd)
+	binary.Read(r, binary.LittleEndian, &pos.dataEnd)
+	binary.Read(r, binary.LittleEndian, &records)
+	binary.Read(r, binary.LittleEndian, &pos.version)
+	binary.Read(r, binary.LittleEndian, &tombstones)
+	pos.records = int(records)
+	pos.tombstones = int(tombstones)
+
+	if version != hintVersion {
+		return logPosition{}, nil, nil, errInvalidHint
+	}
+
+	tailLength, err := binary.ReadUvarint(r)
+	if err != nil || tailLength > uint64(r.Len()) {
+		return logPosition{}, nil, nil, errInvalidHint
+	}
+
+	tail := make([]byte, tailLength)
+	r.Read(tail)
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return logPosition{}, nil, nil, errInvalidHint
+	}
+
+	items := make([]*Item, 0, min(count, uint64(r.Len())))
+
+	for i := uint64(0); i < count; i++ {
+		keyLength, err := binary.ReadUvarint(r)
+		if err != nil || keyLength > uint64(r.Len()) {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		key := make([]byte, keyLength)
+		r.Read(key)
+
+		offset, err := binary.ReadVarint(r)
+		if err != nil {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		checksummed, err := r.ReadByte()
+		if err != nil {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		var checksum uint32
+		if err := binary.Read(r, binary.LittleEndian, &checksum); err != nil {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		version, err := binary.ReadUvarint(r)
+		if err != nil {
+			return logPosition{}, nil, nil, errInvalidHint
+		}
+
+		items = append(items, &Item{Key: string(key), Value: valueMetadata{
+			offset:      typeOffset(offset),
+			length:      int(length),
+			checksum:    checksum,
+			checksummed: checksummed == 1,
+			version:     version,
+		}})
+	}
+
+	return pos, tail, items, nil
+}
@@ -0,0 +1,211 @@
+package onetable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func fillTable(t *testing.T, table *OneTable, from int, to int) {
+	for i := from; i < to; i++ {
+		key := fmt.Sprintf("key%03d", i%50)
+		if err := table.Insert(key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if i%7 == 0 {
+			if err := table.Delete(key); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+}
+
+func compareTables(t *testing.T, expected *OneTable, actual *OneTable) {
+	want, err := expected.Between("", "\xff")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := actual.Between("", "\xff")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(want) != len(got) {
+		t.Fatalf("Expected %d items, Got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if want[i].Key != got[i].Key || !bytes.Equal(want[i].Value, got[i].Value) {
+			t.Fatalf("Expected %s: %s, Got %s: %s", want[i].Key, want[i].Value, got[i].Key, got[i].Value)
+		}
+	}
+}
+
+func TestSnapshotReplaysTail(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fillTable(t, table, 0, 200)
+
+	if err := table.Snapshot(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fillTable(t, table, 200, 300)
+
+	// garble the part of the index log covered by the snapshot, except for
+	// the tail the hint checks. Opening the table must not read it.
+	indexPath := path.Join(folder, indexFileName)
+	index, _ := os.ReadFile(indexPath)
+	pos, _, _, err := decodeHint(mustRead(t, path.Join(folder, hintFileName)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	garbled := append(index[:headerSize:headerSize], bytes.Repeat([]byte{'#'}, int(pos.indexEnd-hintTailSize-headerSize))...)
+	garbled = append(garbled, index[pos.indexEnd-hintTailSize:]...)
+	os.WriteFile(indexPath, garbled, 0644)
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if reopened.Recovery().Repaired() {
+		t.Fatalf("Unexpected repair: %s", reopened.Recovery())
+	}
+
+	compareTables(t, table, reopened)
+}
+
+func mustRead(t *testing.T, p string) []byte {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return b
+}
+
+func TestSnapshotDamagedHintIsIgnored(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fillTable(t, table, 0, 100)
+	table.Snapshot()
+	fillTable(t, table, 100, 150)
+
+	hintPath := path.Join(folder, hintFileName)
+	hint := mustRead(t, hintPath)
+	hint[len(hint)/2] ^= 0xff
+	os.WriteFile(hintPath, hint, 0644)
+
+	reopened, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	compareTables(t, table, reopened)
+}
+
+func TestSnapshotEvery(t *testing.T) {
+	folder := t.TempDir()
+	table, err := NewWithOptions(folder, NewIndexHashTable(), Options{SnapshotEvery: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	hintPath := path.Join(folder, hintFileName)
+
+	fillTable(t, table, 0, 5)
+	if _, err := os.Stat(hintPath); !os.IsNotExist(err) {
+		t.Fatal("Snapshot written before 10 writes")
+	}
+
+	fillTable(t, table, 5, 20)
+	pos, _, _, err := decodeHint(mustRead(t, hintPath))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if pos.records < 10 {
+		t.Fatalf("Snapshot covers %d records. Expected at least 10", pos.records)
+	}
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := os.Stat(hintPath); !os.IsNotExist(err) {
+		t.Fatal("Compact did not remove the stale snapshot")
+	}
+
+	fillTable(t, table, 20, 40)
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	compareTables(t, table, reopened)
+}
+
+func TestSnapshotLostTail(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+	table.Snapshot()
+
+	hintPath := path.Join(folder, hintFileName)
+	hint := mustRead(t, hintPath)
+	table.Close()
+
+	// the tail covered by the hint is lost, as if it was never synced
+	indexPath := path.Join(folder, indexFileName)
+	info, _ := os.Stat(indexPath)
+	os.Truncate(indexPath, info.Size()-3)
+
+	reopened, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := os.Stat(hintPath); !os.IsNotExist(err) {
+		t.Fatal("Hint covering the lost tail was not removed")
+	}
+
+	// grow the files past the end of the old hint and bring it back
+	reopened.Insert("c", []byte("val c"))
+	reopened.Insert("d", []byte("val d"))
+	reopened.Close()
+	os.WriteFile(hintPath, hint, 0644)
+
+	again, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if again.Has("b") {
+		t.Fatal("Lost key b came back from a stale hint")
+	}
+
+	for _, key := range []string{"a", "c", "d"} {
+		if v, _ := again.Get(key); string(v) != "val "+key {
+			t.Fatalf("Expected 'val %s', Got %s", key, v)
+		}
+	}
+}
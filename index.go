@@ -0,0 +1,120 @@
+package onetable
+
+import (
+	"errors"
+	"strings"
+)
+
+// ValueMetadata locates a value in the data file. Indexes store it as an
+// opaque value for a key and hand it back unchanged.
+type ValueMetadata interface {
+	// Offset returns the position of the value in the data file
+	Offset() int64
+	Length() int
+	// Checksum returns the CRC32C of the value. The second return value is
+	// false for values written before checksums were recorded
+	Checksum() (uint32, bool)
+	// Version returns the version of the key set by the write that stored
+	// the value. In formatBinary versions only grow, also across deletes,
+	// Compact and reopening. Legacy formats number them anew on every load.
+	Version() uint64
+}
+
+type valueMetadata struct {
+	offset      typeOffset
+	length      int
+	checksum    uint32
+	checksummed bool
+	version     uint64
+}
+
+// NewValueMetadata creates metadata for a value of the given length stored at
+// offset. It is meant for testing Index implementations, OneTable creates
+// the metadata of the values it writes itself.
+func NewValueMetadata(offset int64, length int) ValueMetadata {
+	return valueMetadata{offset: typeOffset(offset), length: length}
+}
+
+func (v valueMetadata) Offset() int64 {
+	return int64(v.offset)
+}
+
+func (v valueMetadata) Length() int {
+	return v.length
+}
+
+func (v valueMetadata) Checksum() (uint32, bool) {
+	return v.checksum, v.checksummed
+}
+
+func (v valueMetadata) Version() uint64 {
+	return v.version
+}
+
+func toValueMetadata(v ValueMetadata) valueMetadata {
+	sum, ok := v.Checksum()
+	return valueMetadata{offset: typeOffset(v.Offset()), length: v.Length(), checksum: sum, checksummed: ok, version: v.Version()}
+}
+
+// Item is a key together with the metadata of its value, as returned by the
+// range operations of an Index
+type Item struct {
+	Key   string
+	Value ValueMetadata
+}
+
+// Index is the in-memory lookup structure of a OneTable, mapping keys to the
+// location of their values in the data file. Implementations outside of this
+// package can be passed to New and should pass the conformance suite in the
+// indextest package.
+//
+// OneTable never calls Insert or Delete concurrently, but Get, Between and
+// Ascend may be called while a write is in progress. Implementations that
+// are not safe for that, like all built-in indexes except IndexSkipList,
+// should not be shared by concurrent readers and writers.
+type Index interface {
+	// Get returns the metadata stored for key and whether the key exists
+	Get(key string) (ValueMetadata, bool)
+	// Insert stores the metadata for key, replacing any previous value
+	Insert(key string, value ValueMetadata) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+	// Between returns the items with fromKey <= key <= toKey ordered by key
+	Between(fromKey string, toKey string) ([]*Item, error)
+	// Ascend calls fn for every item in key order until fn returns false
+	Ascend(fn func(*Item) bool) error
+}
+
+// RangeIndex is implemented by indexes that can walk a key range in either
+// direction without collecting it first. OneTable iterators use it to fetch
+// metadata a few keys at a time and fall back to Between for other indexes.
+type RangeIndex interface {
+	// AscendRange calls fn for the items with fromKey <= key <= toKey in
+	// key order until fn returns false
+	AscendRange(fromKey string, toKey string, fn func(*Item) bool) error
+	// DescendRange calls fn for the items with fromKey <= key <= toKey in
+	// reverse key order until fn returns false
+	DescendRange(fromKey string, toKey string, fn func(*Item) bool) error
+}
+
+// ErrFullScan is returned by indexes that cannot visit the keys of a prefix
+// without scanning all of them
+var ErrFullScan = errors.New("Index needs a full scan")
+
+// PrefixIndex is implemented by indexes that can visit the keys starting with
+// a prefix without scanning the others. OneTable.Prefix uses it and falls
+// back to a full scan for other indexes or when it returns ErrFullScan.
+type PrefixIndex interface {
+	// AscendPrefix calls fn for the items whose key starts with prefix and
+	// is at least fromKey, in key order until fn returns false. fromKey lets
+	// a scan resume after the last key it visited.
+	AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error
+}
+
+// untilPrefixEnds wraps fn for a walk starting at prefix, stopping it at the
+// first key that does not start with prefix
+func untilPrefixEnds(prefix string, fn func(*Item) bool) func(*Item) bool {
+	return func(it *Item) bool {
+		return strings.HasPrefix(it.Key, prefix) && fn(it)
+	}
+}
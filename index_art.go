@@ -0,0 +1,476 @@
+package onetable
+
+import (
+	"sort"
+	"strings"
+)
+
+// IndexART is an adaptive radix tree. Shared key prefixes are stored once,
+// compressed into the inner nodes, which suits long hierarchical keys such
+// as "tenant/123/orders/...". Inner nodes grow from 4 to 16, 48 and 256
+// children as needed and shrink back when children are removed.
+type IndexART struct {
+	root *artNode
+}
+
+type artKind uint8
+
+const (
+	artNode4 artKind = iota
+	artNode16
+	artNode48
+	artNode256
+)
+
+type artLeaf struct {
+	key   string
+	value ValueMetadata
+}
+
+type artNode struct {
+	kind artKind
+	// prefix is the compressed path between the parent's edge and this node
+	prefix string
+	// leaf holds the key that ends exactly at this node, if any
+	leaf *artLeaf
+	size int
+	// keys holds the sorted edge bytes of node4 and node16
+	keys []byte
+	// index maps an edge byte to its slot in children + 1 for node48
+	index *[256]uint8
+	// children is ordered like keys for node4 and node16, holds 48 slots
+	// for node48 and is indexed by the edge byte for node256
+	children []*artNode
+}
+
+func NewIndexART() *IndexART {
+	return &IndexART{}
+}
+
+func newARTLeafNode(key string, depth int, valueMeta ValueMetadata) *artNode {
+	return &artNode{kind: artNode4, prefix: key[depth:], leaf: &artLeaf{key: key, value: valueMeta}}
+}
+
+func artCapacity(kind artKind) int {
+	switch kind {
+	case artNode4:
+		return 4
+	case artNode16:
+		return 16
+	case artNode48:
+		return 48
+	}
+	return 256
+}
+
+func (n *artNode) findChild(b byte) *artNode {
+	switch n.kind {
+	case artNode4, artNode16:
+		if i, ok := n.searchKeys(b); ok {
+			return n.children[i]
+		}
+	case artNode48:
+		if slot := n.index[b]; slot != 0 {
+			return n.children[slot-1]
+		}
+	case artNode256:
+		return n.children[b]
+	}
+
+	return nil
+}
+
+func (n *artNode) searchKeys(b byte) (int, bool) {
+	i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+	return i, i < len(n.keys) && n.keys[i] == b
+}
+
+// entries returns the edge bytes and children of the node in ascending order
+func (n *artNode) entries() ([]byte, []*artNode) {
+	switch n.kind {
+	case artNode4, artNode16:
+		return n.keys, n.children
+	}
+
+	keys := make([]byte, 0, n.size)
+	children := make([]*artNode, 0, n.size)
+
+	for b := 0; b < 256; b++ {
+		if child := n.findChild(byte(b)); child != nil {
+			keys = append(keys, byte(b))
+			children = append(children, child)
+		}
+	}
+
+	return keys, children
+}
+
+// resize converts the node to kind, keeping its children
+func (n *artNode) resize(kind artKind) {
+	keys, children := n.entries()
+	keys = append([]byte{}, keys...)
+	children = append([]*artNode{}, children...)
+
+	n.kind = kind
+	n.keys, n.index, n.children = nil, nil, nil
+
+	switch kind {
+	case artNode4, artNode16:
+		n.keys = keys
+		n.children = children
+	case artNode48:
+		n.index = &[256]uint8{}
+		n.children = make([]*artNode, 48)
+		for i, b := range keys {
+			n.index[b] = uint8(i + 1)
+			n.children[i] = children[i]
+		}
+	case artNode256:
+		n.children = make([]*artNode, 256)
+		for i, b := range keys {
+			n.children[b] = children[i]
+		}
+	}
+}
+
+func (n *artNode) addChild(b byte, child *artNode) {
+	if n.size == artCapacity(n.kind) {
+		n.resize(n.kind + 1)
+	}
+
+	switch n.kind {
+	case artNode4, artNode16:
+		i, _ := n.searchKeys(b)
+		n.keys = insertAt(n.keys, i, b)
+		n.children = insertAt(n.children, i, child)
+	case artNode48:
+		for slot, c := range n.children {
+			if c == nil {
+				n.children[slot] = child
+				n.index[b] = uint8(slot + 1)
+				break
+			}
+		}
+	case artNode256:
+		n.children[b] = child
+	}
+
+	n.size++
+}
+
+func (n *artNode) replaceChild(b byte, child *artNode) {
+	switch n.kind {
+	case artNode4, artNode16:
+		i, _ := n.searchKeys(b)
+		n.children[i] = child
+	case artNode48:
+		n.children[n.index[b]-1] = child
+	case artNode256:
+		n.children[b] = child
+	}
+}
+
+func (n *artNode) removeChild(b byte) {
+	switch n.kind {
+	case artNode4, artNode16:
+		i, _ := n.searchKeys(b)
+		n.keys = removeAt(n.keys, i)
+		n.children = removeAt(n.children, i)
+	case artNode48:
+		n.children[n.index[b]-1] = nil
+		n.index[b] = 0
+	case artNode256:
+		n.children[b] = nil
+	}
+
+	n.size--
+
+	// shrink with some slack, so that a node at the boundary does not
+	// flip between two kinds
+	switch {
+	case n.kind == artNode256 && n.size <= 40:
+		n.resize(artNode48)
+	case n.kind == artNode48 && n.size <= 12:
+		n.resize(artNode16)
+	case n.kind == artNode16 && n.size <= 3:
+		n.resize(artNode4)
+	}
+}
+
+func commonPrefixLength(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (index *IndexART) Get(key string) (ValueMetadata, bool) {
+	n := index.root
+	depth := 0
+
+	for n != nil {
+		if !strings.HasPrefix(key[depth:], n.prefix) {
+			return nil, false
+		}
+
+		depth += len(n.prefix)
+		if depth == len(key) {
+			if n.leaf == nil {
+				return nil, false
+			}
+			return n.leaf.value, true
+		}
+
+		n = n.findChild(key[depth])
+		depth++
+	}
+
+	return nil, false
+}
+
+func artInsert(n *artNode, key string, depth int, valueMeta ValueMetadata) *artNode {
+	if n == nil {
+		return newARTLeafNode(key, depth, valueMeta)
+	}
+
+	p := commonPrefixLength(n.prefix, key[depth:])
+
+	if p < len(n.prefix) {
+		// the key diverges inside the compressed path, split it
+		parent := &artNode{kind: artNode4, prefix: n.prefix[:p]}
+		edge := n.prefix[p]
+		n.prefix = n.prefix[p+1:]
+		parent.addChild(edge, n)
+
+		if depth+p == len(key) {
+			parent.leaf = &artLeaf{key: key, value: valueMeta}
+		} else {
+			parent.addChild(key[depth+p], newARTLeafNode(key, depth+p+1, valueMeta))
+		}
+
+		return parent
+	}
+
+	depth += len(n.prefix)
+	if depth == len(key) {
+		if n.leaf != nil {
+			n.leaf.value = valueMeta
+		} else {
+			n.leaf = &artLeaf{key: key, value: valueMeta}
+		}
+		return n
+	}
+
+	edge := key[depth]
+	child := n.findChild(edge)
+
+	if child == nil {
+		n.addChild(edge, newARTLeafNode(key, depth+1, valueMeta))
+		return n
+	}
+
+	if newChild := artInsert(child, key, depth+1, valueMeta); newChild != child {
+		n.replaceChild(edge, newChild)
+	}
+
+	return n
+}
+
+func (index *IndexART) Insert(key string, valueMeta ValueMetadata) error {
+	index.root = artInsert(index.root, key, 0, valueMeta)
+	return nil
+}
+
+func artDelete(n *artNode, key string, depth int) *artNode {
+	if n == nil || !strings.HasPrefix(key[depth:], n.prefix) {
+		return n
+	}
+
+	depth += len(n.prefix)
+	if depth == len(key) {
+		n.leaf = nil
+	} else {
+		edge := key[depth]
+		child := n.findChild(edge)
+		if child == nil {
+			return n
+		}
+
+		newChild := artDelete(child, key, depth+1)
+		if newChild == nil {
+			n.removeChild(edge)
+		} else if newChild != child {
+			n.replaceChild(edge, newChild)
+		}
+	}
+
+	if n.leaf != nil {
+		return n
+	}
+
+	switch n.size {
+	case 0:
+		return nil
+	case 1:
+		// a node with a single child and no leaf is merged into the child
+		keys, children := n.entries()
+		child := children[0]
+		child.prefix = n.prefix + string([]byte{keys[0]}) + child.prefix
+		return child
+	}
+
+	return n
+}
+
+func (index *IndexART) Delete(key string) error {
+	index.root = artDelete(index.root, key, 0)
+	return nil
+}
+
+// artWalk visits the keys of the subtree in ascending order until fn returns
+// false. path is the key prefix leading to the node, excluding its prefix.
+// Subtrees that lie completely outside [fromKey, toKey] are skipped, with
+// unbounded meaning no upper bound.
+func artWalk(n *artNode, path []byte, fromKey string, toKey string, unbounded bool, fn func(*Item) bool) bool {
+	path = append(path, n.prefix...)
+
+	// every key in the subtree starts with path
+	if !unbounded && string(path) > toKey {
+		return false
+	}
+
+	if string(path) < fromKey && !strings.HasPrefix(fromKey, string(path)) {
+		return true
+	}
+
+	if n.leaf != nil && n.leaf.key >= fromKey {
+		if !unbounded && n.leaf.key > toKey {
+			return false
+		}
+
+		if !fn(&Item{Key: n.leaf.key, Value: n.leaf.value}) {
+			return false
+		}
+	}
+
+	keys, children := n.entries()
+	for i, child := range children {
+		if child == nil {
+			continue
+		}
+
+		if !artWalk(child, append(path, keys[i]), fromKey, toKey, unbounded, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// artWalkReverse visits the keys of the subtree from fromKey to toKey in
+// descending order until fn returns false. The leaf of a node is a prefix of
+// every key below it, so it comes last.
+func artWalkReverse(n *artNode, path []byte, fromKey string, toKey string, fn func(*Item) bool) bool {
+	path = append(path, n.prefix...)
+
+	// every key in the subtree starts with path
+	if string(path) > toKey {
+		return true
+	}
+
+	if string(path) < fromKey && !strings.HasPrefix(fromKey, string(path)) {
+		return false
+	}
+
+	keys, children := n.entries()
+	for i := len(children) - 1; i >= 0; i-- {
+		if children[i] == nil {
+			continue
+		}
+
+		if !artWalkReverse(children[i], append(path, keys[i]), fromKey, toKey, fn) {
+			return false
+		}
+	}
+
+	if n.leaf != nil && n.leaf.key <= toKey {
+		if n.leaf.key < fromKey {
+			return false
+		}
+
+		if !fn(&Item{Key: n.leaf.key, Value: n.leaf.value}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (index *IndexART) Between(fromKey string, toKey string) ([]*Item, error) {
+	var res []*Item
+	index.AscendRange(fromKey, toKey, func(it *Item) bool {
+		res = append(res, it)
+		return true
+	})
+	return res, nil
+}
+
+func (index *IndexART) BetweenRange(r Range) ([]*Item, error) {
+	if index.root == nil {
+		return nil, nil
+	}
+
+	return collectRange(r, func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+		artWalk(index.root, nil, fromKey, toKey, unbounded, fn)
+	}), nil
+}
+
+func (index *IndexART) AscendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	if index.root != nil {
+		artWalk(index.root, nil, fromKey, toKey, false, fn)
+	}
+	return nil
+}
+
+func (index *IndexART) DescendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	if index.root != nil {
+		artWalkReverse(index.root, nil, fromKey, toKey, fn)
+	}
+	return nil
+}
+
+func (index *IndexART) Ascend(fn func(*Item) bool) error {
+	if index.root != nil {
+		artWalk(index.root, nil, "", "", true, fn)
+	}
+	return nil
+}
+
+// AscendPrefix visits the keys starting with prefix. Only the subtree below
+// the prefix is walked.
+func (index *IndexART) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	n := index.root
+	depth := 0
+
+	for n != nil {
+		rest := prefix[depth:]
+
+		if len(rest) <= len(n.prefix) {
+			if strings.HasPrefix(n.prefix, rest) {
+				artWalk(n, []byte(prefix[:depth]), fromKey, "", true, fn)
+			}
+			break
+		}
+
+		if !strings.HasPrefix(rest, n.prefix) {
+			break
+		}
+
+		depth += len(n.prefix)
+		n = n.findChild(prefix[depth])
+		depth++
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package onetable
+
+import (
+	"fmt"
+	mrand "math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestARTRandomOperations(t *testing.T) {
+	index := NewIndexART()
+	expected := map[string]typeOffset{}
+
+	// short keys from a small alphabet share lots of prefixes and are often
+	// prefixes of each other
+	n := 3000
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, mrand.Intn(6))
+		for j := range b {
+			b[j] = "abc/"[mrand.Intn(4)]
+		}
+		keys[i] = string(b)
+	}
+
+	for i := 0; i < 10*n; i++ {
+		key := keys[mrand.Intn(n)]
+
+		if mrand.Intn(3) == 0 {
+			if err := index.Delete(key); err != nil {
+				t.Fatal(err.Error())
+			}
+			delete(expected, key)
+		} else {
+			if err := index.Insert(key, valueMetadata{offset: typeOffset(i)}); err != nil {
+				t.Fatal(err.Error())
+			}
+			expected[key] = typeOffset(i)
+		}
+	}
+
+	for _, key := range keys {
+		v, found := index.Get(key)
+		offset, ok := expected[key]
+
+		if found != ok {
+			t.Fatalf("Key %q found: %t, expected: %t", key, found, ok)
+		}
+
+		if found && v.Offset() != int64(offset) {
+			t.Fatalf("Wrong offset %d for key %q. Expected %d", v.Offset(), key, offset)
+		}
+	}
+
+	sorted := make([]string, 0, len(expected))
+	for key := range expected {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var ascended []string
+	index.Ascend(func(it *Item) bool {
+		ascended = append(ascended, it.Key)
+		return true
+	})
+
+	if strings.Join(ascended, ",") != strings.Join(sorted, ",") {
+		t.Fatalf("Ascend returned %v, expected %v", ascended, sorted)
+	}
+
+	for _, bounds := range [][2]string{{"", "zzz"}, {"a", "b"}, {"ab", "ab/"}, {"b/", "c"}, {"c", "a"}} {
+		between, err := index.Between(bounds[0], bounds[1])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var want []string
+		for _, key := range sorted {
+			if key >= bounds[0] && key <= bounds[1] {
+				want = append(want, key)
+			}
+		}
+
+		if len(between) != len(want) {
+			t.Fatalf("Between %q and %q returned %d items, expected %d", bounds[0], bounds[1], len(between), len(want))
+		}
+
+		for i, item := range between {
+			if item.Key != want[i] {
+				t.Fatalf("Keys do not match. Expected %q, Got %q", want[i], item.Key)
+			}
+		}
+	}
+
+	for key := range expected {
+		index.Delete(key)
+	}
+
+	if index.root != nil {
+		t.Fatal("Tree is not empty after deleting all keys")
+	}
+}
+
+func TestARTNodeGrowth(t *testing.T) {
+	index := NewIndexART()
+
+	for b := 0; b < 256; b++ {
+		index.Insert("key/"+string([]byte{byte(b)}), valueMetadata{offset: typeOffset(b)})
+	}
+
+	node := index.root
+	if node.prefix != "key/" || node.kind != artNode256 || node.size != 256 {
+		t.Fatalf("Expected node256 with prefix key/, Got kind %d with prefix %q", node.kind, node.prefix)
+	}
+
+	for b := 0; b < 256; b++ {
+		v, found := index.Get("key/" + string([]byte{byte(b)}))
+		if !found || v.Offset() != int64(b) {
+			t.Fatalf("Key with last byte %d not found", b)
+		}
+	}
+
+	for b := 255; b >= 2; b-- {
+		index.Delete("key/" + string([]byte{byte(b)}))
+
+		switch b {
+		case 40:
+			if node.kind != artNode48 {
+				t.Fatalf("Expected node48 with %d children, Got %d", node.size, node.kind)
+			}
+		case 12:
+			if node.kind != artNode16 {
+				t.Fatalf("Expected node16 with %d children, Got %d", node.size, node.kind)
+			}
+		case 3:
+			if node.kind != artNode4 {
+				t.Fatalf("Expected node4 with %d children, Got %d", node.size, node.kind)
+			}
+		}
+	}
+
+	items, _ := index.Between("", "z")
+	if len(items) != 2 || items[0].Key != "key/\x00" || items[1].Key != "key/\x01" {
+		t.Fatalf("Unexpected items after shrinking: %d", len(items))
+	}
+}
+
+func TestARTPrefix(t *testing.T) {
+	index := NewIndexART()
+
+	keys := []string{
+		"tenant/1/orders/2026-10-17/a",
+		"tenant/1/orders/2026-10-18/a",
+		"tenant/1/orders/2026-10-18/b",
+		"tenant/1/users/42",
+		"tenant/12/orders/2026-10-18/a",
+		"tenant/2/orders/2026-10-18/a",
+		"tenant/1",
+	}
+
+	for i, key := range keys {
+		index.Insert(key, valueMetadata{offset: typeOffset(i)})
+	}
+
+	cases := map[string][]string{
+		"tenant/1/orders/2026-10-18": {"tenant/1/orders/2026-10-18/a", "tenant/1/orders/2026-10-18/b"},
+		"tenant/1/":                  {"tenant/1/orders/2026-10-17/a", "tenant/1/orders/2026-10-18/a", "tenant/1/orders/2026-10-18/b", "tenant/1/users/42"},
+		"tenant/1":                   {"tenant/1", "tenant/1/orders/2026-10-17/a", "tenant/1/orders/2026-10-18/a", "tenant/1/orders/2026-10-18/b", "tenant/1/users/42", "tenant/12/orders/2026-10-18/a"},
+		"tenant/3":                   nil,
+		"tenant/1/orders/2026-10-19": nil,
+		"x":                          nil,
+	}
+
+	for prefix, expected := range cases {
+		items, err := collectPrefix(index, prefix)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if len(items) != len(expected) {
+			t.Fatalf("Prefix %q returned %d items, expected %d", prefix, len(items), len(expected))
+		}
+
+		for i, item := range items {
+			if item.Key != expected[i] {
+				t.Fatalf("Keys do not match. Expected %s, Got %s", expected[i], item.Key)
+			}
+		}
+	}
+
+	all, _ := collectPrefix(index, "")
+	if len(all) != len(keys) {
+		t.Fatalf("Empty prefix returned %d items, expected %d", len(all), len(keys))
+	}
+}
+
+func TestARTSortedInsert(t *testing.T) {
+	index := NewIndexART()
+
+	n := 100_000
+	for i := 0; i < n; i++ {
+		index.Insert(fmt.Sprintf("tenant/%d/orders/%08d", i%10, i), valueMetadata{offset: typeOffset(i)})
+	}
+
+	for i := 0; i < n; i++ {
+		v, found := index.Get(fmt.Sprintf("tenant/%d/orders/%08d", i%10, i))
+		if !found || v.Offset() != int64(i) {
+			t.Fatalf("Key %d not found", i)
+		}
+	}
+
+	items, _ := collectPrefix(index, "tenant/3/")
+	if len(items) != n/10 {
+		t.Fatalf("Expected %d items, Got %d", n/10, len(items))
+	}
+}
+
+func collectPrefix(index PrefixIndex, prefix string) ([]*Item, error) {
+	var res []*Item
+	err := index.AscendPrefix(prefix, "", func(it *Item) bool {
+		res = append(res, it)
+		return true
+	})
+	return res, err
+}
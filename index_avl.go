@@ -0,0 +1,288 @@
+package onetable
+
+// IndexAVL is a self-balancing binary search tree. Unlike IndexBST it stays
+// O(log n) deep when keys are inserted in sorted order.
+type IndexAVL struct {
+	root *AVLNode
+}
+
+type AVLNode struct {
+	key    string
+	value  ValueMetadata
+	height int
+	left   *AVLNode
+	right  *AVLNode
+}
+
+func NewIndexAVL() *IndexAVL {
+	return &IndexAVL{}
+}
+
+func avlHeight(node *AVLNode) int {
+	if node == nil {
+		return 0
+	}
+
+	return node.height
+}
+
+func (node *AVLNode) updateHeight() {
+	node.height = 1 + max(avlHeight(node.left), avlHeight(node.right))
+}
+
+func (node *AVLNode) balanceFactor() int {
+	return avlHeight(node.left) - avlHeight(node.right)
+}
+
+func rotateRight(node *AVLNode) *AVLNode {
+	left := node.left
+	node.left = left.right
+	left.right = node
+
+	node.updateHeight()
+	left.updateHeight()
+
+	return left
+}
+
+func rotateLeft(node *AVLNode) *AVLNode {
+	right := node.right
+	node.right = right.left
+	right.left = node
+
+	node.updateHeight()
+	right.updateHeight()
+
+	return right
+}
+
+// rebalance restores the AVL invariant of node, whose subtrees differ in
+// height by at most 2, and returns the new root of the subtree
+func rebalance(node *AVLNode) *AVLNode {
+	node.updateHeight()
+
+	switch balance := node.balanceFactor(); {
+	case balance > 1:
+		if node.left.balanceFactor() < 0 {
+			node.left = rotateLeft(node.left)
+		}
+		return rotateRight(node)
+	case balance < -1:
+		if node.right.balanceFactor() > 0 {
+			node.right = rotateRight(node.right)
+		}
+		return rotateLeft(node)
+	}
+
+	return node
+}
+
+func (index *IndexAVL) Get(key string) (ValueMetadata, bool) {
+	current := index.root
+
+	for current != nil {
+		if current.key == key {
+			return current.value, true
+		}
+
+		if key < current.key {
+			current = current.left
+		} else {
+			current = current.right
+		}
+	}
+
+	return nil, false
+}
+
+func avlInsert(node *AVLNode, key string, valueMeta ValueMetadata) *AVLNode {
+	if node == nil {
+		return &AVLNode{key: key, value: valueMeta, height: 1}
+	}
+
+	if key == node.key {
+		node.value = valueMeta
+		return node
+	}
+
+	if key < node.key {
+		node.left = avlInsert(node.left, key, valueMeta)
+	} else {
+		node.right = avlInsert(node.right, key, valueMeta)
+	}
+
+	return rebalance(node)
+}
+
+func (index *IndexAVL) Insert(key string, valueMeta ValueMetadata) error {
+	index.root = avlInsert(index.root, key, valueMeta)
+	return nil
+}
+
+// avlDeleteMin removes the smallest node of the subtree and returns it
+// together with the new root of the subtree
+func avlDeleteMin(node *AVLNode) (*AVLNode, *AVLNode) {
+	if node.left == nil {
+		return node, node.right
+	}
+
+	var smallest *AVLNode
+	smallest, node.left = avlDeleteMin(node.left)
+
+	return smallest, rebalance(node)
+}
+
+func avlDelete(node *AVLNode, key string) *AVLNode {
+	if node == nil {
+		return nil
+	}
+
+	if key < node.key {
+		node.left = avlDelete(node.left, key)
+	} else if key > node.key {
+		node.right = avlDelete(node.right, key)
+	} else {
+		if node.left == nil {
+			return node.right
+		}
+
+		if node.right == nil {
+			return node.left
+		}
+
+		// Two children
+		// replace the node with the smallest node of the right subtree
+		smallest, right := avlDeleteMin(node.right)
+		smallest.left = node.left
+		smallest.right = right
+		node = smallest
+	}
+
+	return rebalance(node)
+}
+
+func (index *IndexAVL) Delete(key string) error {
+	index.root = avlDelete(index.root, key)
+	return nil
+}
+
+func (index *IndexAVL) Between(fromKey string, toKey string) ([]*Item, error) {
+	var res []*Item
+	index.AscendRange(fromKey, toKey, func(it *Item) bool {
+		res = append(res, it)
+		return true
+	})
+	return res, nil
+}
+
+func (index *IndexAVL) BetweenRange(r Range) ([]*Item, error) {
+	return collectRange(r, func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+		avlAscendRange(index.root, fromKey, toKey, unbounded, fn)
+	}), nil
+}
+
+func (index *IndexAVL) AscendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	avlAscendRange(index.root, fromKey, toKey, false, fn)
+	return nil
+}
+
+func (index *IndexAVL) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	avlAscendRange(index.root, max(prefix, fromKey), "", true, untilPrefixEnds(prefix, fn))
+	return nil
+}
+
+// avlAscendRange walks the tree in order with an explicit stack, skipping
+// the subtrees that lie outside of the range. unbounded means no upper bound.
+func avlAscendRange(root *AVLNode, fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+	var stack []*AVLNode
+	current := root
+
+	for current != nil || len(stack) > 0 {
+		for current != nil {
+			if current.key < fromKey {
+				current = current.right
+				continue
+			}
+
+			stack = append(stack, current)
+			current = current.left
+		}
+
+		if len(stack) == 0 {
+			break
+		}
+
+		current = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !unbounded && current.key > toKey {
+			break
+		}
+
+		if !fn(&Item{Key: current.key, Value: current.value}) {
+			break
+		}
+
+		current = current.right
+	}
+}
+
+// DescendRange mirrors AscendRange, walking from the right
+func (index *IndexAVL) DescendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	var stack []*AVLNode
+	current := index.root
+
+	for current != nil || len(stack) > 0 {
+		for current != nil {
+			if current.key > toKey {
+				current = current.left
+				continue
+			}
+
+			stack = append(stack, current)
+			current = current.right
+		}
+
+		if len(stack) == 0 {
+			break
+		}
+
+		current = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if current.key < fromKey {
+			break
+		}
+
+		if !fn(&Item{Key: current.key, Value: current.value}) {
+			break
+		}
+
+		current = current.left
+	}
+
+	return nil
+}
+
+func (index *IndexAVL) Ascend(fn func(*Item) bool) error {
+	var stack []*AVLNode
+	current := index.root
+
+	for current != nil || len(stack) > 0 {
+		for current != nil {
+			stack = append(stack, current)
+			current = current.left
+		}
+
+		current = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !fn(&Item{Key: current.key, Value: current.value}) {
+			return nil
+		}
+
+		current = current.right
+	}
+
+	return nil
+}
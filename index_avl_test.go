@@ -0,0 +1,178 @@
+package onetable
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// checkAVL verifies ordering, heights and balance of the subtree and returns
+// its height
+func checkAVL(t *testing.T, node *AVLNode, lower *string, upper *string) int {
+	if node == nil {
+		return 0
+	}
+
+	if (lower != nil && node.key <= *lower) || (upper != nil && node.key >= *upper) {
+		t.Fatalf("Node %s breaks the ordering", node.key)
+	}
+
+	left := checkAVL(t, node.left, lower, &node.key)
+	right := checkAVL(t, node.right, &node.key, upper)
+
+	if left-right > 1 || right-left > 1 {
+		t.Fatalf("Node %s is not balanced. Left height %d, Right height %d", node.key, left, right)
+	}
+
+	if node.height != 1+max(left, right) {
+		t.Fatalf("Node %s has height %d. Expected %d", node.key, node.height, 1+max(left, right))
+	}
+
+	return node.height
+}
+
+func TestAVLSortedInsert(t *testing.T) {
+	index := NewIndexAVL()
+
+	n := 1_000_000
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = fmt.Sprintf("%020d", i)
+		if err := index.Insert(keys[i], valueMetadata{offset: typeOffset(i)}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// an AVL tree with n nodes is at most 1.44 * log2(n) deep
+	if h := checkAVL(t, index.root, nil, nil); h > 29 {
+		t.Fatalf("Tree of height %d is too deep", h)
+	}
+
+	for i, key := range keys {
+		v, found := index.Get(key)
+		if !found {
+			t.Fatalf("Node %s not found", key)
+		}
+
+		if v.Offset() != int64(i) {
+			t.Fatalf("Wrong offset %d for key %s. Expected %d", v.Offset(), key, i)
+		}
+	}
+
+	between, err := index.Between(keys[1000], keys[n-1000])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(between) != n-1999 {
+		t.Fatalf("Expected %d items, Got %d", n-1999, len(between))
+	}
+
+	for i, item := range between {
+		if item.Key != keys[1000+i] {
+			t.Fatalf("Keys do not match. Expected %s, Got %s", keys[1000+i], item.Key)
+		}
+	}
+
+	for i := 0; i < n; i += 2 {
+		if err := index.Delete(keys[i]); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	checkAVL(t, index.root, nil, nil)
+
+	for i, key := range keys {
+		_, found := index.Get(key)
+		if found != (i%2 == 1) {
+			t.Fatalf("Key %s found: %t after deleting even keys", key, found)
+		}
+	}
+}
+
+func TestAVLInsertOverwrite(t *testing.T) {
+	index := NewIndexAVL()
+
+	index.Insert("a", valueMetadata{offset: 1})
+	index.Insert("a", valueMetadata{offset: 2})
+
+	v, found := index.Get("a")
+	if !found || v.Offset() != 2 {
+		t.Fatal("Expected overwritten value with offset 2")
+	}
+
+	if index.root.left != nil || index.root.right != nil {
+		t.Fatal("Overwrite created a new node")
+	}
+}
+
+func TestAVLDelete(t *testing.T) {
+	index := NewIndexAVL()
+
+	if err := index.Delete("missing"); err != nil {
+		t.Fatal("Expecting no error when deleting in empty tree")
+	}
+
+	n := 1000
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = rand.Text()
+		index.Insert(keys[i], valueMetadata{})
+	}
+
+	for i := 0; i < n/2; i++ {
+		if err := index.Delete(keys[i]); err != nil {
+			t.Fatal(err.Error())
+		}
+		checkAVL(t, index.root, nil, nil)
+	}
+
+	var remaining []string
+	index.Ascend(func(it *Item) bool {
+		remaining = append(remaining, it.Key)
+		return true
+	})
+
+	expected := keys[n/2:]
+	sort.Strings(expected)
+
+	if len(remaining) != len(expected) {
+		t.Fatalf("Expected %d keys, Got %d", len(expected), len(remaining))
+	}
+
+	for i := range expected {
+		if remaining[i] != expected[i] {
+			t.Fatalf("Keys do not match. Expected %s, Got %s", expected[i], remaining[i])
+		}
+	}
+}
+
+func TestAVLBetween(t *testing.T) {
+	index := NewIndexAVL()
+
+	for _, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e"} {
+		index.Insert(key, valueMetadata{})
+	}
+
+	between, err := index.Between("c", "d")
+	if err != nil {
+		t.Fatal("Valid index.between call failed")
+	}
+
+	expected := []string{"c", "c0", "c1", "c2", "d"}
+	if len(between) != len(expected) {
+		t.Fatalf("Expected %d items, Got %d", len(expected), len(between))
+	}
+
+	for i, item := range between {
+		if item.Key != expected[i] {
+			t.Fatalf("Keys do not match. Expected %s, Got %s", expected[i], item.Key)
+		}
+	}
+
+	between, _ = index.Between("f", "z")
+	if len(between) != 0 {
+		t.Fatalf("Expected empty range, Got %d items", len(between))
+	}
+}
@@ -2,11 +2,15 @@ package onetable
 
 import (
 	"crypto/rand"
+	"flag"
+	"fmt"
 	mrand "math/rand"
 	"sort"
 	"testing"
 )
 
+var benchTableSize = flag.Int64("onetable.benchsize", 256<<20, "Size in bytes of the data file used by BenchmarkTableReadPath")
+
 func BenchmarkIndexInsert(b *testing.B) {
 	n := 1000
 	keys := make([]string, n)
@@ -16,12 +20,14 @@ func BenchmarkIndexInsert(b *testing.B) {
 
 	indexHashTable := NewIndexHashTable()
 	indexBST := NewIndexBST()
+	indexAVL := NewIndexAVL()
+	indexBTree := NewIndexBTree(DefaultBTreeDegree)
 
 	b.Run("Hashtable insert",
 		func(b *testing.B) {
 			for b.Loop() {
 				for i, key := range keys {
-					err := indexHashTable.insert(key, valueMetadata{offset: typeOffset(i), length: 128})
+					err := indexHashTable.Insert(key, valueMetadata{offset: typeOffset(i), length: 128})
 					if err != nil {
 						b.Fatal(err.Error())
 					}
@@ -34,7 +40,33 @@ func BenchmarkIndexInsert(b *testing.B) {
 		func(b *testing.B) {
 			for b.Loop() {
 				for i, key := range keys {
-					err := indexBST.insert(key, valueMetadata{offset: typeOffset(i), length: 128})
+					err := indexBST.Insert(key, valueMetadata{offset: typeOffset(i), length: 128})
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+				}
+			}
+		},
+	)
+
+	b.Run("AVL insert",
+		func(b *testing.B) {
+			for b.Loop() {
+				for i, key := range keys {
+					err := indexAVL.Insert(key, valueMetadata{offset: typeOffset(i), length: 128})
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+				}
+			}
+		},
+	)
+
+	b.Run("BTree insert",
+		func(b *testing.B) {
+			for b.Loop() {
+				for i, key := range keys {
+					err := indexBTree.Insert(key, valueMetadata{offset: typeOffset(i), length: 128})
 					if err != nil {
 						b.Fatal(err.Error())
 					}
@@ -51,7 +83,7 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("Hashtable get", func(b *testing.B) {
 		for b.Loop() {
 			for _, idx := range indexesToGet {
-				_, found := indexHashTable.get(keys[idx])
+				_, found := indexHashTable.Get(keys[idx])
 				if !found {
 					b.Fatal("Node not found")
 				}
@@ -62,7 +94,29 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("BST get", func(b *testing.B) {
 		for b.Loop() {
 			for _, idx := range indexesToGet {
-				_, found := indexBST.get(keys[idx])
+				_, found := indexBST.Get(keys[idx])
+				if !found {
+					b.Fatal("Node not found")
+				}
+			}
+		}
+	})
+
+	b.Run("AVL get", func(b *testing.B) {
+		for b.Loop() {
+			for _, idx := range indexesToGet {
+				_, found := indexAVL.Get(keys[idx])
+				if !found {
+					b.Fatal("Node not found")
+				}
+			}
+		}
+	})
+
+	b.Run("BTree get", func(b *testing.B) {
+		for b.Loop() {
+			for _, idx := range indexesToGet {
+				_, found := indexBTree.Get(keys[idx])
 				if !found {
 					b.Fatal("Node not found")
 				}
@@ -82,7 +136,7 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("Hashtable between", func(b *testing.B) {
 		for b.Loop() {
 			for i := 0; i < n; i++ {
-				items, err := indexHashTable.between(keys[leftIdx[i]], keys[rightIdx[i]])
+				items, err := indexHashTable.Between(keys[leftIdx[i]], keys[rightIdx[i]])
 				if err != nil {
 					b.Fatal(err.Error())
 				}
@@ -97,7 +151,37 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("BST between", func(b *testing.B) {
 		for b.Loop() {
 			for i := 0; i < n; i++ {
-				items, err := indexBST.between(keys[leftIdx[i]], keys[rightIdx[i]])
+				items, err := indexBST.Between(keys[leftIdx[i]], keys[rightIdx[i]])
+
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+				if len(items) != rightIdx[i]-leftIdx[i]+1 {
+					b.Fatal("Items length does not equal range")
+				}
+			}
+		}
+	})
+
+	b.Run("AVL between", func(b *testing.B) {
+		for b.Loop() {
+			for i := 0; i < n; i++ {
+				items, err := indexAVL.Between(keys[leftIdx[i]], keys[rightIdx[i]])
+
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+				if len(items) != rightIdx[i]-leftIdx[i]+1 {
+					b.Fatal("Items length does not equal range")
+				}
+			}
+		}
+	})
+
+	b.Run("BTree between", func(b *testing.B) {
+		for b.Loop() {
+			for i := 0; i < n; i++ {
+				items, err := indexBTree.Between(keys[leftIdx[i]], keys[rightIdx[i]])
 
 				if err != nil {
 					b.Fatal(err.Error())
@@ -112,11 +196,11 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("Hashtable delete and insert", func(b *testing.B) {
 		for b.Loop() {
 			for _, key := range keys {
-				err := indexHashTable.delete(key)
+				err := indexHashTable.Delete(key)
 				if err != nil {
 					b.Fatal(err.Error())
 				}
-				err = indexHashTable.insert(key, valueMetadata{})
+				err = indexHashTable.Insert(key, valueMetadata{})
 				if err != nil {
 					b.Fatal(err.Error())
 				}
@@ -127,11 +211,41 @@ func BenchmarkIndexInsert(b *testing.B) {
 	b.Run("BST delete and insert", func(b *testing.B) {
 		for b.Loop() {
 			for _, key := range keys {
-				err := indexBST.delete(key)
+				err := indexBST.Delete(key)
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+				err = indexBST.Insert(key, valueMetadata{})
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+			}
+		}
+	})
+
+	b.Run("AVL delete and insert", func(b *testing.B) {
+		for b.Loop() {
+			for _, key := range keys {
+				err := indexAVL.Delete(key)
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+				err = indexAVL.Insert(key, valueMetadata{})
+				if err != nil {
+					b.Fatal(err.Error())
+				}
+			}
+		}
+	})
+
+	b.Run("BTree delete and insert", func(b *testing.B) {
+		for b.Loop() {
+			for _, key := range keys {
+				err := indexBTree.Delete(key)
 				if err != nil {
 					b.Fatal(err.Error())
 				}
-				err = indexBST.insert(key, valueMetadata{})
+				err = indexBTree.Insert(key, valueMetadata{})
 				if err != nil {
 					b.Fatal(err.Error())
 				}
@@ -139,3 +253,191 @@ func BenchmarkIndexInsert(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkTable(b *testing.B) {
+	n := 1000
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = rand.Text()
+	}
+
+	value := make([]byte, 128)
+	rand.Read(value)
+
+	indexesToGet := make([]int, n)
+	for i := 0; i < n; i++ {
+		indexesToGet[i] = mrand.Intn(n)
+	}
+
+	sortedKeys := make([]string, n)
+	copy(sortedKeys, keys)
+	sort.Strings(sortedKeys)
+
+	leftIdx := make([]int, n)
+	rightIdx := make([]int, n)
+	for i := 0; i < n; i++ {
+		leftIdx[i] = mrand.Intn(n - indexesToGet[i])
+		rightIdx[i] = leftIdx[i] + indexesToGet[i]
+	}
+
+	indexes := []struct {
+		name     string
+		newIndex func() Index
+	}{
+		{"Hashtable", func() Index { return NewIndexHashTable() }},
+		{"BST", func() Index { return NewIndexBST() }},
+		{"AVL", func() Index { return NewIndexAVL() }},
+		{"BTree", func() Index { return NewIndexBTree(DefaultBTreeDegree) }},
+		{"SkipList", func() Index { return NewIndexSkipList() }},
+		{"ART", func() Index { return NewIndexART() }},
+	}
+
+	for _, index := range indexes {
+		table, err := New(b.TempDir(), index.newIndex())
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+
+		b.Run(index.name+" table insert", func(b *testing.B) {
+			for b.Loop() {
+				for _, key := range keys {
+					if err := table.Insert(key, value); err != nil {
+						b.Fatal(err.Error())
+					}
+				}
+			}
+		})
+
+		b.Run(index.name+" table get", func(b *testing.B) {
+			for b.Loop() {
+				for _, idx := range indexesToGet {
+					v, err := table.Get(keys[idx])
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+
+					if v == nil {
+						b.Fatal("Value not found")
+					}
+				}
+			}
+		})
+
+		b.Run(index.name+" table between", func(b *testing.B) {
+			for b.Loop() {
+				for i := 0; i < n; i++ {
+					items, err := table.Between(sortedKeys[leftIdx[i]], sortedKeys[rightIdx[i]])
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+
+					if len(items) != rightIdx[i]-leftIdx[i]+1 {
+						b.Fatal("Items length does not equal range")
+					}
+				}
+			}
+		})
+
+		b.Run(index.name+" table delete and insert", func(b *testing.B) {
+			for b.Loop() {
+				for _, key := range keys {
+					if err := table.Delete(key); err != nil {
+						b.Fatal(err.Error())
+					}
+
+					if err := table.Insert(key, value); err != nil {
+						b.Fatal(err.Error())
+					}
+				}
+			}
+		})
+
+		if err := table.Close(); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+// BenchmarkTableReadPath compares pread and mmap reads. The size of the table
+// is set with -onetable.benchsize, see the bench-readpath make target.
+func BenchmarkTableReadPath(b *testing.B) {
+	valueSize := 4096
+	n := int(*benchTableSize) / valueSize
+	rangeSize := 100
+
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = fmt.Sprintf("%016d", i)
+	}
+
+	value := make([]byte, valueSize)
+	rand.Read(value)
+
+	folder := b.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	// insert in random order so that the BST stays reasonably balanced
+	for _, i := range mrand.Perm(n) {
+		if err := table.Insert(keys[i], value); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+
+	if err := table.Close(); err != nil {
+		b.Fatal(err.Error())
+	}
+
+	indexesToGet := make([]int, 1000)
+	for i := range indexesToGet {
+		indexesToGet[i] = mrand.Intn(n - rangeSize)
+	}
+
+	for _, mmap := range []bool{false, true} {
+		name := "pread"
+		if mmap {
+			name = "mmap"
+		}
+
+		table, err := NewWithOptions(folder, NewIndexBST(), Options{Mmap: mmap})
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+
+		b.Run(name+" get", func(b *testing.B) {
+			for b.Loop() {
+				for _, idx := range indexesToGet {
+					v, err := table.Get(keys[idx])
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+
+					if len(v) != valueSize {
+						b.Fatal("Value has wrong size")
+					}
+				}
+			}
+		})
+
+		b.Run(name+" between", func(b *testing.B) {
+			for b.Loop() {
+				for _, idx := range indexesToGet[:100] {
+					items, err := table.Between(keys[idx], keys[idx+rangeSize-1])
+					if err != nil {
+						b.Fatal(err.Error())
+					}
+
+					if len(items) != rangeSize {
+						b.Fatal("Items length does not equal range")
+					}
+				}
+			}
+		})
+
+		if err := table.Close(); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
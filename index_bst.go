@@ -15,7 +15,7 @@ func NewIndexBST() *IndexBST {
 	return &IndexBST{}
 }
 
-func (index *IndexBST) get(key string) (ValueMetadata, bool) {
+func (index *IndexBST) Get(key string) (ValueMetadata, bool) {
 	current := index.root
 
 	for current != nil {
@@ -33,7 +33,7 @@ func (index *IndexBST) get(key string) (ValueMetadata, bool) {
 	return nil, false
 }
 
-func (index *IndexBST) insert(key string, valueMeta ValueMetadata) error {
+func (index *IndexBST) Insert(key string, valueMeta ValueMetadata) error {
 	newNode := &BSTNode{key: key, value: valueMeta}
 
 	if index.root == nil {
@@ -65,7 +65,7 @@ func (index *IndexBST) insert(key string, valueMeta ValueMetadata) error {
 	return nil
 }
 
-func (index *IndexBST) delete(key string) error {
+func (index *IndexBST) Delete(key string) error {
 	var parent *BSTNode
 	current := index.root
 
@@ -139,26 +139,92 @@ func inorder(buffer *[]*BSTNode, node *BSTNode) {
 	}
 }
 
-func inorderBetween(buffer *[]*item, node *BSTNode, fromKey string, toKey string) {
+// bstAscendRange visits the keys from fromKey to toKey in order until fn
+// returns false, with unbounded meaning no upper bound
+func bstAscendRange(node *BSTNode, fromKey string, toKey string, unbounded bool, fn func(*Item) bool) bool {
 	if node == nil {
-		return
+		return true
 	}
 
-	if fromKey < node.key {
-		inorderBetween(buffer, node.left, fromKey, toKey)
+	if fromKey < node.key && !bstAscendRange(node.left, fromKey, toKey, unbounded, fn) {
+		return false
+	}
+
+	if node.key >= fromKey && (unbounded || node.key <= toKey) && !fn(&Item{Key: node.key, Value: node.value}) {
+		return false
+	}
+
+	if unbounded || toKey > node.key {
+		return bstAscendRange(node.right, fromKey, toKey, unbounded, fn)
+	}
+
+	return true
+}
+
+func bstDescendRange(node *BSTNode, fromKey string, toKey string, fn func(*Item) bool) bool {
+	if node == nil {
+		return true
 	}
 
-	if node.key >= fromKey && node.key <= toKey {
-		*buffer = append(*buffer, &item{Key: node.key, Value: node.value})
+	if toKey > node.key && !bstDescendRange(node.right, fromKey, toKey, fn) {
+		return false
 	}
 
-	if toKey > node.key {
-		inorderBetween(buffer, node.right, fromKey, toKey)
+	if node.key >= fromKey && node.key <= toKey && !fn(&Item{Key: node.key, Value: node.value}) {
+		return false
 	}
+
+	if fromKey < node.key {
+		return bstDescendRange(node.left, fromKey, toKey, fn)
+	}
+
+	return true
 }
 
-func (index *IndexBST) between(fromKey string, toKey string) ([]*item, error) {
-	var res []*item
-	inorderBetween(&res, index.root, fromKey, toKey)
-	return res, nil
+func (index *IndexBST) Between(fromKey string, toKey string) ([]*Item, error) {
+	return index.BetweenRange(Range{Start: Incl(fromKey), End: Incl(toKey)})
+}
+
+func (index *IndexBST) BetweenRange(r Range) ([]*Item, error) {
+	return collectRange(r, func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+		bstAscendRange(index.root, fromKey, toKey, unbounded, fn)
+	}), nil
+}
+
+func (index *IndexBST) AscendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	bstAscendRange(index.root, fromKey, toKey, false, fn)
+	return nil
+}
+
+func (index *IndexBST) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	bstAscendRange(index.root, max(prefix, fromKey), "", true, untilPrefixEnds(prefix, fn))
+	return nil
+}
+
+func (index *IndexBST) DescendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	bstDescendRange(index.root, fromKey, toKey, fn)
+	return nil
+}
+
+func (index *IndexBST) Ascend(fn func(*Item) bool) error {
+	var stack []*BSTNode
+	current := index.root
+
+	for current != nil || len(stack) > 0 {
+		for current != nil {
+			stack = append(stack, current)
+			current = current.left
+		}
+
+		current = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !fn(&Item{Key: current.key, Value: current.value}) {
+			return nil
+		}
+
+		current = current.right
+	}
+
+	return nil
 }
@@ -9,7 +9,7 @@ import (
 func TestBSTInsert(t *testing.T) {
 	bst := NewIndexBST()
 
-	err := bst.insert("d", valueMetadata{})
+	err := bst.Insert("d", valueMetadata{})
 	if err != nil {
 		t.Fatal("Expected nil error after inserting root")
 	}
@@ -18,7 +18,7 @@ func TestBSTInsert(t *testing.T) {
 		t.Fatal("root key not correct")
 	}
 
-	err = bst.insert("a", valueMetadata{})
+	err = bst.Insert("a", valueMetadata{})
 	if err != nil {
 		t.Fatal("Expected nil error after inserting 'a'")
 	}
@@ -27,7 +27,7 @@ func TestBSTInsert(t *testing.T) {
 		t.Fatal("root.left key not correct")
 	}
 
-	err = bst.insert("f", valueMetadata{})
+	err = bst.Insert("f", valueMetadata{})
 	if err != nil {
 		t.Fatal("Expected nil error after inserting 'f'")
 	}
@@ -41,7 +41,7 @@ func TestBSTInsert(t *testing.T) {
 	}
 
 	offset := typeOffset(123)
-	err = bst.insert("f", valueMetadata{offset: offset})
+	err = bst.Insert("f", valueMetadata{offset: offset})
 
 	if err != nil {
 		t.Fatal("Expected nil error after inserting 'f'")
@@ -51,7 +51,7 @@ func TestBSTInsert(t *testing.T) {
 		t.Fatal("root.right key not correct")
 	}
 
-	if bst.root.right.value.Offset() != offset {
+	if bst.root.right.value.Offset() != int64(offset) {
 		t.Fatalf("root.right.value.offset not %d", offset)
 	}
 }
@@ -59,14 +59,14 @@ func TestBSTInsert(t *testing.T) {
 func TestBSTGet(t *testing.T) {
 	bst := NewIndexBST()
 
-	_, found := bst.get("key")
+	_, found := bst.Get("key")
 
 	if found {
 		t.Fatal("Expecting no node found in empty tree")
 	}
 
 	for i, k := range []string{"d", "a", "b", "f"} {
-		err := bst.insert(k, valueMetadata{offset: typeOffset(i)})
+		err := bst.Insert(k, valueMetadata{offset: typeOffset(i)})
 
 		if err != nil {
 			t.Fatal(err.Error())
@@ -74,12 +74,12 @@ func TestBSTGet(t *testing.T) {
 	}
 
 	for i, k := range []string{"d", "a", "b", "f"} {
-		v, found := bst.get(k)
+		v, found := bst.Get(k)
 		if !found {
 			t.Fatalf("Node %s not found", k)
 		}
 
-		if v.Offset() != typeOffset(i) {
+		if v.Offset() != int64(i) {
 			t.Fatalf("Wrong offset %d for key %s. Expected %d", v.Offset(), k, i)
 		}
 	}
@@ -87,14 +87,14 @@ func TestBSTGet(t *testing.T) {
 
 func TestBSTDeleteLeaf(t *testing.T) {
 	bst := NewIndexBST()
-	err := bst.delete("key")
+	err := bst.Delete("key")
 
 	if err != nil {
 		t.Fatal("Expecting no error when deleting in empty tree")
 	}
 
-	bst.insert("b", valueMetadata{})
-	bst.insert("a", valueMetadata{})
+	bst.Insert("b", valueMetadata{})
+	bst.Insert("a", valueMetadata{})
 
 	if bst.root.key != "b" {
 		t.Fatalf("Expected root to be 'b'. Found %s", bst.root.key)
@@ -108,7 +108,7 @@ func TestBSTDeleteLeaf(t *testing.T) {
 		t.Fatalf("bst.root.left not 'a'. Found %s", bst.root.left.key)
 	}
 
-	bst.delete("b")
+	bst.Delete("b")
 
 	if bst.root == nil {
 		t.Fatal("Root is nil. Expected 'a' node to be promoted")
@@ -122,14 +122,14 @@ func TestBSTDeleteLeaf(t *testing.T) {
 		t.Fatal("root left and right is not nil")
 	}
 
-	bst.delete("a")
+	bst.Delete("a")
 
 	if bst.root != nil {
 		t.Fatal("Root is not nil. Expected tree to be empty")
 	}
 
 	for i, k := range []string{"d", "b", "a", "c1", "c0", "c2", "f", "e", "g"} {
-		err := bst.insert(k, valueMetadata{offset: typeOffset(i)})
+		err := bst.Insert(k, valueMetadata{offset: typeOffset(i)})
 
 		if err != nil {
 			t.Fatal(err.Error())
@@ -137,7 +137,7 @@ func TestBSTDeleteLeaf(t *testing.T) {
 	}
 
 	// try removing leaf
-	err = bst.delete("g")
+	err = bst.Delete("g")
 
 	if err != nil {
 		t.Fatal("Expecting no error when deleting node g")
@@ -151,7 +151,7 @@ func TestBSTDeleteLeaf(t *testing.T) {
 		t.Fatal("Root.right.left node is not e")
 	}
 
-	_, found := bst.get("g")
+	_, found := bst.Get("g")
 	if found {
 		t.Fatal("Node g found in tree even after deletion")
 	}
@@ -161,7 +161,7 @@ func TestBSTDeleteOneChild(t *testing.T) {
 	bst := NewIndexBST()
 
 	for i, k := range []string{"d", "b", "a", "c1", "c0", "c2", "f", "e"} {
-		err := bst.insert(k, valueMetadata{offset: typeOffset(i)})
+		err := bst.Insert(k, valueMetadata{offset: typeOffset(i)})
 
 		if err != nil {
 			t.Fatal(err.Error())
@@ -169,7 +169,7 @@ func TestBSTDeleteOneChild(t *testing.T) {
 	}
 
 	// try removing node with only one children
-	err := bst.delete("f")
+	err := bst.Delete("f")
 
 	if err != nil {
 		t.Fatal("Expecting no error when node f")
@@ -179,7 +179,7 @@ func TestBSTDeleteOneChild(t *testing.T) {
 		t.Fatal("Root.right node.key is not e")
 	}
 
-	_, found := bst.get("f")
+	_, found := bst.Get("f")
 	if found {
 		t.Fatal("Node f found in tree even after deletion")
 	}
@@ -188,7 +188,7 @@ func TestBSTDeleteOneChild(t *testing.T) {
 func TestBSTDeleteTwoChildren(t *testing.T) {
 	bst := NewIndexBST()
 	for i, k := range []string{"d", "b", "a", "c1", "c0", "c2", "f"} {
-		err := bst.insert(k, valueMetadata{offset: typeOffset(i)})
+		err := bst.Insert(k, valueMetadata{offset: typeOffset(i)})
 
 		if err != nil {
 			t.Fatal(err.Error())
@@ -196,7 +196,7 @@ func TestBSTDeleteTwoChildren(t *testing.T) {
 	}
 
 	// try removing node with only one children
-	err := bst.delete("b")
+	err := bst.Delete("b")
 
 	if err != nil {
 		t.Fatal("Expecting no error when node f")
@@ -214,7 +214,7 @@ func TestBSTDeleteTwoChildren(t *testing.T) {
 		t.Fatal("Root.left.left node.key is not a")
 	}
 
-	_, found := bst.get("b")
+	_, found := bst.Get("b")
 	if found {
 		t.Fatal("Node b found in tree even after deletion")
 	}
@@ -224,7 +224,7 @@ func TestBSTInorder(t *testing.T) {
 	bst := NewIndexBST()
 	items := []string{"d", "b", "a", "c", "f", "e", "g"}
 	for i, k := range items {
-		err := bst.insert(k, valueMetadata{offset: typeOffset(i)})
+		err := bst.Insert(k, valueMetadata{offset: typeOffset(i)})
 
 		if err != nil {
 			t.Fatal(err.Error())
@@ -249,7 +249,7 @@ func TestBSTBetween(t *testing.T) {
 	keys := make([]string, n)
 	for i := 0; i < n; i++ {
 		key := rand.Text()
-		err := index.insert(key, valueMetadata{})
+		err := index.Insert(key, valueMetadata{})
 		keys[i] = key
 		if err != nil {
 			t.Fatalf("Failed to insert key %s", key)
@@ -260,7 +260,7 @@ func TestBSTBetween(t *testing.T) {
 	indexStart := 20
 	indexEnd := 80
 
-	between, err := index.between(keys[indexStart], keys[indexEnd])
+	between, err := index.Between(keys[indexStart], keys[indexEnd])
 
 	if err != nil {
 		t.Fatal("Valid index.between call failed")
@@ -0,0 +1,391 @@
+package onetable
+
+import "sort"
+
+const DefaultBTreeDegree = 32
+
+// IndexBTree is an in-memory B-tree. Every node holds between degree-1 and
+// 2*degree-1 keys in a contiguous slice, which keeps lookups and range scans
+// cache friendly compared to the one-node-per-key binary trees.
+type IndexBTree struct {
+	root   *BTreeNode
+	degree int
+}
+
+type BTreeNode struct {
+	keys     []string
+	values   []ValueMetadata
+	children []*BTreeNode
+}
+
+// NewIndexBTree creates a B-tree with the given minimum degree. Degrees
+// below 2 fall back to DefaultBTreeDegree.
+func NewIndexBTree(degree int) *IndexBTree {
+	if degree < 2 {
+		degree = DefaultBTreeDegree
+	}
+
+	return &IndexBTree{degree: degree}
+}
+
+func (node *BTreeNode) leaf() bool {
+	return len(node.children) == 0
+}
+
+// search returns the position of key in the node, or the position of the
+// child to descend into if the key is not in the node
+func (node *BTreeNode) search(key string) (int, bool) {
+	i := sort.SearchStrings(node.keys, key)
+	return i, i < len(node.keys) && node.keys[i] == key
+}
+
+func (index *IndexBTree) Get(key string) (ValueMetadata, bool) {
+	current := index.root
+
+	for current != nil {
+		i, found := current.search(key)
+		if found {
+			return current.values[i], true
+		}
+
+		if current.leaf() {
+			break
+		}
+
+		current = current.children[i]
+	}
+
+	return nil, false
+}
+
+func (index *IndexBTree) full(node *BTreeNode) bool {
+	return len(node.keys) == 2*index.degree-1
+}
+
+// splitChild splits the full i-th child of node around its median key,
+// which moves up into node
+func (index *IndexBTree) splitChild(node *BTreeNode, i int) {
+	t := index.degree
+	child := node.children[i]
+
+	right := &BTreeNode{
+		keys:   append([]string{}, child.keys[t:]...),
+		values: append([]ValueMetadata{}, child.values[t:]...),
+	}
+
+	if !child.leaf() {
+		right.children = append([]*BTreeNode{}, child.children[t:]...)
+		child.children = child.children[:t]
+	}
+
+	medianKey, medianValue := child.keys[t-1], child.values[t-1]
+	child.keys = child.keys[:t-1]
+	child.values = child.values[:t-1]
+
+	node.keys = insertAt(node.keys, i, medianKey)
+	node.values = insertAt(node.values, i, medianValue)
+	node.children = insertAt(node.children, i+1, right)
+}
+
+func insertAt[T any](s []T, i int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+func removeAt[T any](s []T, i int) []T {
+	copy(s[i:], s[i+1:])
+	var zero T
+	s[len(s)-1] = zero
+	return s[:len(s)-1]
+}
+
+func (index *IndexBTree) Insert(key string, valueMeta ValueMetadata) error {
+	if index.root == nil {
+		index.root = &BTreeNode{keys: []string{key}, values: []ValueMetadata{valueMeta}}
+		return nil
+	}
+
+	if index.full(index.root) {
+		index.root = &BTreeNode{children: []*BTreeNode{index.root}}
+		index.splitChild(index.root, 0)
+	}
+
+	// full nodes are split on the way down, so there is always room for
+	// the key in the leaf
+	current := index.root
+	for {
+		i, found := current.search(key)
+		if found {
+			current.values[i] = valueMeta
+			return nil
+		}
+
+		if current.leaf() {
+			current.keys = insertAt(current.keys, i, key)
+			current.values = insertAt(current.values, i, valueMeta)
+			return nil
+		}
+
+		if index.full(current.children[i]) {
+			index.splitChild(current, i)
+
+			if key == current.keys[i] {
+				current.values[i] = valueMeta
+				return nil
+			}
+
+			if key > current.keys[i] {
+				i++
+			}
+		}
+
+		current = current.children[i]
+	}
+}
+
+// merge joins the i-th and i+1-th children of node together with the key
+// separating them
+func (index *IndexBTree) merge(node *BTreeNode, i int) {
+	left, right := node.children[i], node.children[i+1]
+
+	left.keys = append(append(left.keys, node.keys[i]), right.keys...)
+	left.values = append(append(left.values, node.values[i]), right.values...)
+	left.children = append(left.children, right.children...)
+
+	node.keys = removeAt(node.keys, i)
+	node.values = removeAt(node.values, i)
+	node.children = removeAt(node.children, i+1)
+}
+
+// fill makes sure the i-th child of node has at least degree keys, by
+// borrowing a key from a sibling or merging with it. It returns the position
+// of the child that now covers the keys of the i-th child.
+func (index *IndexBTree) fill(node *BTreeNode, i int) int {
+	t := index.degree
+	child := node.children[i]
+
+	if i > 0 && len(node.children[i-1].keys) >= t {
+		left := node.children[i-1]
+		last := len(left.keys) - 1
+
+		child.keys = insertAt(child.keys, 0, node.keys[i-1])
+		child.values = insertAt(child.values, 0, node.values[i-1])
+		node.keys[i-1], node.values[i-1] = left.keys[last], left.values[last]
+		left.keys, left.values = left.keys[:last], left.values[:last]
+
+		if !left.leaf() {
+			child.children = insertAt(child.children, 0, left.children[len(left.children)-1])
+			left.children = left.children[:len(left.children)-1]
+		}
+
+		return i
+	}
+
+	if i < len(node.keys) && len(node.children[i+1].keys) >= t {
+		right := node.children[i+1]
+
+		child.keys = append(child.keys, node.keys[i])
+		child.values = append(child.values, node.values[i])
+		node.keys[i], node.values[i] = right.keys[0], right.values[0]
+		right.keys, right.values = removeAt(right.keys, 0), removeAt(right.values, 0)
+
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = removeAt(right.children, 0)
+		}
+
+		return i
+	}
+
+	if i < len(node.keys) {
+		index.merge(node, i)
+		return i
+	}
+
+	index.merge(node, i-1)
+	return i - 1
+}
+
+func (index *IndexBTree) deleteFrom(node *BTreeNode, key string) {
+	t := index.degree
+
+	for {
+		i, found := node.search(key)
+
+		if found && node.leaf() {
+			node.keys = removeAt(node.keys, i)
+			node.values = removeAt(node.values, i)
+			return
+		}
+
+		if found {
+			left, right := node.children[i], node.children[i+1]
+
+			if len(left.keys) >= t {
+				// replace with the predecessor and delete it from the left subtree
+				pred := left
+				for !pred.leaf() {
+					pred = pred.children[len(pred.children)-1]
+				}
+
+				last := len(pred.keys) - 1
+				node.keys[i], node.values[i] = pred.keys[last], pred.values[last]
+				key = pred.keys[last]
+				node = left
+				continue
+			}
+
+			if len(right.keys) >= t {
+				// replace with the successor and delete it from the right subtree
+				succ := right
+				for !succ.leaf() {
+					succ = succ.children[0]
+				}
+
+				node.keys[i], node.values[i] = succ.keys[0], succ.values[0]
+				key = succ.keys[0]
+				node = right
+				continue
+			}
+
+			index.merge(node, i)
+			node = left
+			continue
+		}
+
+		if node.leaf() {
+			return
+		}
+
+		if len(node.children[i].keys) < t {
+			i = index.fill(node, i)
+		}
+
+		node = node.children[i]
+	}
+}
+
+func (index *IndexBTree) Delete(key string) error {
+	if index.root == nil {
+		return nil
+	}
+
+	index.deleteFrom(index.root, key)
+
+	if len(index.root.keys) == 0 {
+		if index.root.leaf() {
+			index.root = nil
+		} else {
+			index.root = index.root.children[0]
+		}
+	}
+
+	return nil
+}
+
+// btreeAscendRange visits the keys from fromKey to toKey in order until fn
+// returns false, with unbounded meaning no upper bound
+func btreeAscendRange(node *BTreeNode, fromKey string, toKey string, unbounded bool, fn func(*Item) bool) bool {
+	i := sort.SearchStrings(node.keys, fromKey)
+
+	for ; i <= len(node.keys); i++ {
+		if !node.leaf() && !btreeAscendRange(node.children[i], fromKey, toKey, unbounded, fn) {
+			return false
+		}
+
+		if i == len(node.keys) {
+			return true
+		}
+
+		if (!unbounded && node.keys[i] > toKey) || !fn(&Item{Key: node.keys[i], Value: node.values[i]}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func btreeDescendRange(node *BTreeNode, fromKey string, toKey string, fn func(*Item) bool) bool {
+	// children[i] holds the keys between keys[i-1] and keys[i]
+	i := sort.Search(len(node.keys), func(j int) bool { return node.keys[j] > toKey })
+
+	for ; i >= 0; i-- {
+		if !node.leaf() && !btreeDescendRange(node.children[i], fromKey, toKey, fn) {
+			return false
+		}
+
+		if i == 0 {
+			return true
+		}
+
+		if node.keys[i-1] < fromKey || !fn(&Item{Key: node.keys[i-1], Value: node.values[i-1]}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (index *IndexBTree) Between(fromKey string, toKey string) ([]*Item, error) {
+	var res []*Item
+	index.AscendRange(fromKey, toKey, func(it *Item) bool {
+		res = append(res, it)
+		return true
+	})
+	return res, nil
+}
+
+func (index *IndexBTree) BetweenRange(r Range) ([]*Item, error) {
+	if index.root == nil {
+		return nil, nil
+	}
+
+	return collectRange(r, func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+		btreeAscendRange(index.root, fromKey, toKey, unbounded, fn)
+	}), nil
+}
+
+func (index *IndexBTree) AscendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	if index.root != nil {
+		btreeAscendRange(index.root, fromKey, toKey, false, fn)
+	}
+	return nil
+}
+
+func (index *IndexBTree) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	if index.root != nil {
+		btreeAscendRange(index.root, max(prefix, fromKey), "", true, untilPrefixEnds(prefix, fn))
+	}
+	return nil
+}
+
+func (index *IndexBTree) DescendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	if index.root != nil {
+		btreeDescendRange(index.root, fromKey, toKey, fn)
+	}
+	return nil
+}
+
+func btreeAscend(node *BTreeNode, fn func(*Item) bool) bool {
+	for i := 0; i <= len(node.keys); i++ {
+		if !node.leaf() && !btreeAscend(node.children[i], fn) {
+			return false
+		}
+
+		if i < len(node.keys) && !fn(&Item{Key: node.keys[i], Value: node.values[i]}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (index *IndexBTree) Ascend(fn func(*Item) bool) error {
+	if index.root != nil {
+		btreeAscend(index.root, fn)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package onetable
+
+import (
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"sort"
+	"testing"
+)
+
+// checkBTree verifies key counts, ordering and that all leaves are at the
+// same depth. It returns the depth of the leaves.
+func checkBTree(t *testing.T, index *IndexBTree, node *BTreeNode, root bool) int {
+	if !root && (len(node.keys) < index.degree-1 || len(node.keys) > 2*index.degree-1) {
+		t.Fatalf("Node holds %d keys. Expected between %d and %d", len(node.keys), index.degree-1, 2*index.degree-1)
+	}
+
+	if !sort.StringsAreSorted(node.keys) {
+		t.Fatal("Node keys are not sorted")
+	}
+
+	if node.leaf() {
+		return 1
+	}
+
+	if len(node.children) != len(node.keys)+1 {
+		t.Fatalf("Node has %d keys and %d children", len(node.keys), len(node.children))
+	}
+
+	depth := -1
+	for i, child := range node.children {
+		if i > 0 && child.keys[0] <= node.keys[i-1] {
+			t.Fatalf("Child key %s not greater than separator %s", child.keys[0], node.keys[i-1])
+		}
+
+		if i < len(node.keys) && child.keys[len(child.keys)-1] >= node.keys[i] {
+			t.Fatalf("Child key %s not lower than separator %s", child.keys[len(child.keys)-1], node.keys[i])
+		}
+
+		d := checkBTree(t, index, child, false)
+		if depth != -1 && d != depth {
+			t.Fatal("Leaves are not at the same depth")
+		}
+		depth = d
+	}
+
+	return depth + 1
+}
+
+func TestBTreeRandomOperations(t *testing.T) {
+	for _, degree := range []int{2, 3, 16} {
+		t.Run(fmt.Sprintf("degree %d", degree), func(t *testing.T) {
+			index := NewIndexBTree(degree)
+			expected := map[string]typeOffset{}
+
+			n := 2000
+			keys := make([]string, n)
+			for i := 0; i < n; i++ {
+				keys[i] = rand.Text()[:4]
+			}
+
+			for i := 0; i < 5*n; i++ {
+				key := keys[mrand.Intn(n)]
+
+				if mrand.Intn(3) == 0 {
+					if err := index.Delete(key); err != nil {
+						t.Fatal(err.Error())
+					}
+					delete(expected, key)
+				} else {
+					if err := index.Insert(key, valueMetadata{offset: typeOffset(i)}); err != nil {
+						t.Fatal(err.Error())
+					}
+					expected[key] = typeOffset(i)
+				}
+
+				if i%100 == 0 && index.root != nil {
+					checkBTree(t, index, index.root, true)
+				}
+			}
+
+			for _, key := range keys {
+				v, found := index.Get(key)
+				offset, ok := expected[key]
+
+				if found != ok {
+					t.Fatalf("Key %s found: %t, expected: %t", key, found, ok)
+				}
+
+				if found && v.Offset() != int64(offset) {
+					t.Fatalf("Wrong offset %d for key %s. Expected %d", v.Offset(), key, offset)
+				}
+			}
+
+			var ascended []string
+			index.Ascend(func(it *Item) bool {
+				ascended = append(ascended, it.Key)
+				return true
+			})
+
+			if len(ascended) != len(expected) || !sort.StringsAreSorted(ascended) {
+				t.Fatalf("Ascend returned %d keys, expected %d sorted keys", len(ascended), len(expected))
+			}
+
+			for key := range expected {
+				index.Delete(key)
+			}
+
+			if index.root != nil {
+				t.Fatal("Tree is not empty after deleting all keys")
+			}
+		})
+	}
+}
+
+func TestBTreeSortedInsert(t *testing.T) {
+	index := NewIndexBTree(DefaultBTreeDegree)
+
+	n := 100_000
+	for i := 0; i < n; i++ {
+		index.Insert(fmt.Sprintf("%020d", i), valueMetadata{offset: typeOffset(i)})
+	}
+
+	if depth := checkBTree(t, index, index.root, true); depth > 4 {
+		t.Fatalf("Tree of depth %d is too deep", depth)
+	}
+}
+
+func TestBTreeBetween(t *testing.T) {
+	index := NewIndexBTree(2)
+
+	n := 100
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = rand.Text()
+		index.Insert(keys[i], valueMetadata{})
+	}
+
+	sort.Strings(keys)
+
+	between, err := index.Between(keys[20], keys[80])
+	if err != nil {
+		t.Fatal("Valid index.between call failed")
+	}
+
+	expected := keys[20:81]
+	if len(between) != len(expected) {
+		t.Fatalf("Expected %d items, Got %d", len(expected), len(between))
+	}
+
+	for i, item := range between {
+		if item.Key != expected[i] {
+			t.Fatalf("Keys do not match. Expected %s, Got %s", expected[i], item.Key)
+		}
+	}
+
+	between, _ = index.Between(keys[n-1]+"0", keys[n-1]+"1")
+	if len(between) != 0 {
+		t.Fatalf("Expected empty range, Got %d items", len(between))
+	}
+
+	if between, _ := NewIndexBTree(2).Between("a", "z"); len(between) != 0 {
+		t.Fatal("Expected empty range in empty tree")
+	}
+}
@@ -16,41 +16,68 @@ func NewIndexHashTable() *IndexHashTable {
 	return &IndexHashTable{index: index}
 }
 
-func (index *IndexHashTable) get(key string) (ValueMetadata, bool) {
+func (index *IndexHashTable) Get(key string) (ValueMetadata, bool) {
 	v, found := index.index[key]
 	return v, found
 }
 
-func (index *IndexHashTable) insert(key string, valueMeta ValueMetadata) error {
+func (index *IndexHashTable) Insert(key string, valueMeta ValueMetadata) error {
 	index.index[key] = valueMeta
 
 	return nil
 }
 
-func (index *IndexHashTable) delete(key string) error {
+func (index *IndexHashTable) Delete(key string) error {
 	delete(index.index, key)
 	return nil
 }
 
-func (index *IndexHashTable) between(fromKey string, toKey string) ([]*item, error) {
+func (index *IndexHashTable) Between(fromKey string, toKey string) ([]*Item, error) {
+	return index.BetweenRange(Range{Start: Incl(fromKey), End: Incl(toKey)})
+}
+
+func (index *IndexHashTable) BetweenRange(r Range) ([]*Item, error) {
 	keys := []string{}
 
 	for k := range index.index {
-		if k >= fromKey && k <= toKey {
+		if r.Contains(k) {
 			keys = append(keys, k)
 		}
 	}
 
-	items := make([]*item, len(keys))
+	items := make([]*Item, len(keys))
 	sort.Strings(keys)
 
 	for i, k := range keys {
-		v, found := index.get(k)
+		v, found := index.Get(k)
 		if !found {
 			return nil, fmt.Errorf("Found no value for key %s", k)
 		}
-		items[i] = &item{Key: k, Value: v}
+		items[i] = &Item{Key: k, Value: v}
 	}
 
 	return items, nil
 }
+
+func (index *IndexHashTable) Ascend(fn func(*Item) bool) error {
+	keys := make([]string, 0, len(index.index))
+	for k := range index.index {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !fn(&Item{Key: k, Value: index.index[k]}) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// AscendPrefix returns ErrFullScan, as a hash table keeps no order among its
+// keys
+func (index *IndexHashTable) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	return ErrFullScan
+}
@@ -10,13 +10,13 @@ func TestIndexHashTableBetween(t *testing.T) {
 	}
 
 	for _, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e"} {
-		err := index.insert(key, valueMetadata{})
+		err := index.Insert(key, valueMetadata{})
 		if err != nil {
 			t.Fatalf("Failed to insert key %s", key)
 		}
 	}
 
-	between, err := index.between("c", "d")
+	between, err := index.Between("c", "d")
 
 	if err != nil {
 		t.Fatal("Valid index.between call failed")
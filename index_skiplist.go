@@ -0,0 +1,208 @@
+package onetable
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+const skipListMaxLevel = 24
+
+// IndexSkipList is an ordered index that can be read from any number of
+// goroutines without locking while a single goroutine writes to it. Writes
+// must be serialized by the caller, which OneTable does with its write lock.
+//
+// Nodes are published bottom-up with atomic stores, so a reader always sees
+// a consistent list at the bottom level. A deleted node keeps pointing
+// forward, so readers standing on it can carry on.
+type IndexSkipList struct {
+	head *skipListNode
+}
+
+type skipListNode struct {
+	key     string
+	value   atomic.Pointer[ValueMetadata]
+	deleted atomic.Bool
+	next    []atomic.Pointer[skipListNode]
+}
+
+func NewIndexSkipList() *IndexSkipList {
+	head := &skipListNode{next: make([]atomic.Pointer[skipListNode], skipListMaxLevel)}
+	return &IndexSkipList{head: head}
+}
+
+// randomLevel returns a level with P(level > n) = 2^-n
+func randomLevel() int {
+	return min(1+bits.TrailingZeros64(rand.Uint64()), skipListMaxLevel)
+}
+
+// seek returns the first node with a key greater than or equal to key. When
+// preds is not nil it is filled with the last node before key on every level.
+func (index *IndexSkipList) seek(key string, preds []*skipListNode) *skipListNode {
+	current := index.head
+	var next *skipListNode
+
+	for level := skipListMaxLevel - 1; level >= 0; level-- {
+		next = current.next[level].Load()
+		for next != nil && next.key < key {
+			current = next
+			next = current.next[level].Load()
+		}
+
+		if preds != nil {
+			preds[level] = current
+		}
+	}
+
+	return next
+}
+
+// before returns the last node with a key less than key, or head
+func (index *IndexSkipList) before(key string) *skipListNode {
+	current := index.head
+
+	for level := skipListMaxLevel - 1; level >= 0; level-- {
+		for next := current.next[level].Load(); next != nil && next.key < key; next = current.next[level].Load() {
+			current = next
+		}
+	}
+
+	return current
+}
+
+func (index *IndexSkipList) Get(key string) (ValueMetadata, bool) {
+	node := index.seek(key, nil)
+
+	if node == nil || node.key != key || node.deleted.Load() {
+		return nil, false
+	}
+
+	return *node.value.Load(), true
+}
+
+func (index *IndexSkipList) Insert(key string, valueMeta ValueMetadata) error {
+	preds := make([]*skipListNode, skipListMaxLevel)
+	node := index.seek(key, preds)
+
+	if node != nil && node.key == key {
+		node.value.Store(&valueMeta)
+		return nil
+	}
+
+	level := randomLevel()
+	node = &skipListNode{key: key, next: make([]atomic.Pointer[skipListNode], level)}
+	node.value.Store(&valueMeta)
+
+	for i := 0; i < level; i++ {
+		node.next[i].Store(preds[i].next[i].Load())
+	}
+
+	// publish bottom-up, so that the node is reachable on the bottom level
+	// before it shows up on the express lanes
+	for i := 0; i < level; i++ {
+		preds[i].next[i].Store(node)
+	}
+
+	return nil
+}
+
+func (index *IndexSkipList) Delete(key string) error {
+	preds := make([]*skipListNode, skipListMaxLevel)
+	node := index.seek(key, preds)
+
+	if node == nil || node.key != key {
+		return nil
+	}
+
+	node.deleted.Store(true)
+
+	// unlink top-down, the node stays reachable on the bottom level the
+	// longest, same as it was published
+	for i := len(node.next) - 1; i >= 0; i-- {
+		preds[i].next[i].Store(node.next[i].Load())
+	}
+
+	return nil
+}
+
+func (index *IndexSkipList) Between(fromKey string, toKey string) ([]*Item, error) {
+	var res []*Item
+	index.AscendRange(fromKey, toKey, func(it *Item) bool {
+		res = append(res, it)
+		return true
+	})
+	return res, nil
+}
+
+func (index *IndexSkipList) BetweenRange(r Range) ([]*Item, error) {
+	return collectRange(r, func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool) {
+		for node := index.seek(fromKey, nil); node != nil && (unbounded || node.key <= toKey); node = node.next[0].Load() {
+			if !node.deleted.Load() && !fn(&Item{Key: node.key, Value: *node.value.Load()}) {
+				return
+			}
+		}
+	}), nil
+}
+
+func (index *IndexSkipList) AscendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	for node := index.seek(fromKey, nil); node != nil && node.key <= toKey; node = node.next[0].Load() {
+		if node.deleted.Load() {
+			continue
+		}
+
+		if !fn(&Item{Key: node.key, Value: *node.value.Load()}) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (index *IndexSkipList) AscendPrefix(prefix string, fromKey string, fn func(*Item) bool) error {
+	fn = untilPrefixEnds(prefix, fn)
+
+	for node := index.seek(max(prefix, fromKey), nil); node != nil; node = node.next[0].Load() {
+		if node.deleted.Load() {
+			continue
+		}
+
+		if !fn(&Item{Key: node.key, Value: *node.value.Load()}) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// DescendRange walks the list backwards by searching for the predecessor of
+// every node, which costs O(log n) per key as nodes only link forward
+func (index *IndexSkipList) DescendRange(fromKey string, toKey string, fn func(*Item) bool) error {
+	node := index.before(toKey)
+	if next := node.next[0].Load(); next != nil && next.key == toKey {
+		node = next
+	}
+
+	for node != index.head && node.key >= fromKey {
+		if !node.deleted.Load() && !fn(&Item{Key: node.key, Value: *node.value.Load()}) {
+			return nil
+		}
+
+		node = index.before(node.key)
+	}
+
+	return nil
+}
+
+func (index *IndexSkipList) Ascend(fn func(*Item) bool) error {
+	for node := index.head.next[0].Load(); node != nil; node = node.next[0].Load() {
+		if node.deleted.Load() {
+			continue
+		}
+
+		if !fn(&Item{Key: node.key, Value: *node.value.Load()}) {
+			return nil
+		}
+	}
+
+	return nil
+}
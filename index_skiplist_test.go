@@ -0,0 +1,190 @@
+package onetable
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestSkipListOperations(t *testing.T) {
+	index := NewIndexSkipList()
+
+	if _, found := index.Get("missing"); found {
+		t.Fatal("Expecting no node found in empty list")
+	}
+
+	n := 1000
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = rand.Text()
+		if err := index.Insert(keys[i], valueMetadata{offset: typeOffset(i)}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	for i, key := range keys {
+		v, found := index.Get(key)
+		if !found || v.Offset() != int64(i) {
+			t.Fatalf("Key %s not found with offset %d", key, i)
+		}
+	}
+
+	index.Insert(keys[0], valueMetadata{offset: -5})
+	if v, _ := index.Get(keys[0]); v.Offset() != -5 {
+		t.Fatal("Insert did not overwrite existing key")
+	}
+
+	for _, key := range keys[:n/2] {
+		if err := index.Delete(key); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	for i, key := range keys {
+		if _, found := index.Get(key); found != (i >= n/2) {
+			t.Fatalf("Key %s found: %t after deleting first half", key, found)
+		}
+	}
+
+	remaining := append([]string{}, keys[n/2:]...)
+	sort.Strings(remaining)
+
+	between, err := index.Between(remaining[10], remaining[100])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(between) != 91 {
+		t.Fatalf("Expected 91 items, Got %d", len(between))
+	}
+
+	for i, item := range between {
+		if item.Key != remaining[10+i] {
+			t.Fatalf("Keys do not match. Expected %s, Got %s", remaining[10+i], item.Key)
+		}
+	}
+}
+
+// TestSkipListConcurrentReaders is meant to be run with -race
+func TestSkipListConcurrentReaders(t *testing.T) {
+	index := NewIndexSkipList()
+
+	n := 200
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				index.Get(keys[mrand.Intn(n)])
+
+				items, _ := index.Between(keys[10], keys[100])
+				for i := 1; i < len(items); i++ {
+					if items[i-1].Key >= items[i].Key {
+						t.Errorf("Between returned unordered keys %s, %s", items[i-1].Key, items[i].Key)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20_000; i++ {
+		key := keys[mrand.Intn(n)]
+		if mrand.Intn(2) == 0 {
+			index.Insert(key, valueMetadata{offset: typeOffset(i)})
+		} else {
+			index.Delete(key)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+// TestSkipListTableStress mixes all table operations from many goroutines.
+// It is meant to be run with -race.
+func TestSkipListTableStress(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexSkipList())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	n := 50
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = fmt.Sprintf("key%02d", i)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				key := keys[mrand.Intn(n)]
+
+				switch mrand.Intn(4) {
+				case 0:
+					if err := table.Insert(key, []byte(key)); err != nil {
+						t.Error(err.Error())
+						return
+					}
+				case 1:
+					if err := table.Delete(key); err != nil {
+						t.Error(err.Error())
+						return
+					}
+				case 2:
+					v, err := table.Get(key)
+					if errors.Is(err, ErrNotFound) {
+						continue
+					}
+
+					if err != nil {
+						t.Error(err.Error())
+						return
+					}
+
+					if string(v) != key {
+						t.Errorf("Expected %s, Got %s", key, v)
+						return
+					}
+				case 3:
+					items, err := table.Between(keys[10], keys[40])
+					if err != nil {
+						t.Error(err.Error())
+						return
+					}
+
+					for _, item := range items {
+						if string(item.Value) != item.Key {
+							t.Errorf("Expected %s, Got %s", item.Key, item.Value)
+							return
+						}
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,434 @@
+// Package indextest provides a conformance suite for implementations of
+// onetable.Index.
+//
+// Run it from a test of the implementing package:
+//
+//	func TestMyIndex(t *testing.T) {
+//		indextest.Run(t, func() onetable.Index { return NewMyIndex() })
+//	}
+package indextest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/tsladecek/onetable"
+)
+
+// Run checks that the indexes created by newIndex behave as OneTable expects.
+// Every subtest starts with a fresh, empty index. Optional interfaces like
+// onetable.RangeIndex, onetable.PrefixIndex and onetable.BoundedIndex are
+// checked when the index implements them.
+func Run(t *testing.T, newIndex func() onetable.Index) {
+	t.Run("GetMissing", func(t *testing.T) { testGetMissing(t, newIndex()) })
+	t.Run("InsertGet", func(t *testing.T) { testInsertGet(t, newIndex()) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newIndex()) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newIndex()) })
+	t.Run("EmptyKey", func(t *testing.T) { testEmptyKey(t, newIndex()) })
+	t.Run("DeleteHighBytes", func(t *testing.T) { testDeleteHighBytes(t, newIndex()) })
+	t.Run("Between", func(t *testing.T) { testBetween(t, newIndex()) })
+	t.Run("Ascend", func(t *testing.T) { testAscend(t, newIndex()) })
+
+	if _, ok := newIndex().(onetable.RangeIndex); ok {
+		t.Run("AscendRange", func(t *testing.T) { testAscendRange(t, newIndex()) })
+		t.Run("DescendRange", func(t *testing.T) { testDescendRange(t, newIndex()) })
+	}
+
+	if _, ok := newIndex().(onetable.BoundedIndex); ok {
+		t.Run("BetweenRange", func(t *testing.T) { testBetweenRange(t, newIndex()) })
+	}
+
+	if _, ok := newIndex().(onetable.PrefixIndex); ok {
+		t.Run("AscendPrefix", func(t *testing.T) { testAscendPrefix(t, newIndex()) })
+	}
+
+	t.Run("RandomOperations", func(t *testing.T) { testRandomOperations(t, newIndex()) })
+}
+
+func meta(i int) onetable.ValueMetadata {
+	return onetable.NewValueMetadata(int64(i), i%100)
+}
+
+func insert(t *testing.T, index onetable.Index, key string, i int) {
+	t.Helper()
+	if err := index.Insert(key, meta(i)); err != nil {
+		t.Fatalf("Insert %q failed: %s", key, err.Error())
+	}
+}
+
+func expectGet(t *testing.T, index onetable.Index, key string, i int) {
+	t.Helper()
+
+	v, found := index.Get(key)
+	if !found {
+		t.Fatalf("Key %q not found", key)
+	}
+
+	if v.Offset() != int64(i) || v.Length() != i%100 {
+		t.Fatalf("Key %q has offset %d and length %d. Expected %d and %d", key, v.Offset(), v.Length(), i, i%100)
+	}
+}
+
+func expectMissing(t *testing.T, index onetable.Index, key string) {
+	t.Helper()
+	if _, found := index.Get(key); found {
+		t.Fatalf("Key %q found, expected it to be missing", key)
+	}
+}
+
+func expectKeys(t *testing.T, what string, items []*onetable.Item, expected []string) {
+	t.Helper()
+
+	if len(items) != len(expected) {
+		t.Fatalf("%s returned %d items, expected %d", what, len(items), len(expected))
+	}
+
+	for i, item := range items {
+		if item.Key != expected[i] {
+			t.Fatalf("%s returned %q at position %d, expected %q", what, item.Key, i, expected[i])
+		}
+	}
+}
+
+func testGetMissing(t *testing.T, index onetable.Index) {
+	expectMissing(t, index, "missing")
+
+	if err := index.Delete("missing"); err != nil {
+		t.Fatalf("Deleting a missing key failed: %s", err.Error())
+	}
+
+	items, err := index.Between("a", "z")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Between on empty index", items, nil)
+}
+
+func testInsertGet(t *testing.T, index onetable.Index) {
+	keys := []string{"d", "a", "b", "f", "c1", "c0", "c2", "e", "g"}
+	for i, key := range keys {
+		insert(t, index, key, i)
+	}
+
+	for i, key := range keys {
+		expectGet(t, index, key, i)
+	}
+
+	expectMissing(t, index, "c")
+	expectMissing(t, index, "h")
+}
+
+func testOverwrite(t *testing.T, index onetable.Index) {
+	insert(t, index, "a", 1)
+	insert(t, index, "a", 2)
+	expectGet(t, index, "a", 2)
+
+	items, err := index.Between("a", "a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Between after overwrite", items, []string{"a"})
+}
+
+func testDelete(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"b", "a", "c"} {
+		insert(t, index, key, i)
+	}
+
+	if err := index.Delete("b"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expectMissing(t, index, "b")
+	expectGet(t, index, "a", 1)
+	expectGet(t, index, "c", 2)
+
+	insert(t, index, "b", 5)
+	expectGet(t, index, "b", 5)
+}
+
+// testDeleteHighBytes deletes keys next to keys with bytes >= 0x80, which
+// are not valid UTF-8 on their own
+func testDeleteHighBytes(t *testing.T, index onetable.Index) {
+	cases := []struct {
+		keep, remove string
+	}{
+		{"tenant/\xc3\xa9", "tenant/x"},
+		{"\xffa\xff", ""},
+		{"\x80", "\x81"},
+	}
+
+	for i, c := range cases {
+		insert(t, index, c.keep, i)
+		insert(t, index, c.remove, i+10)
+
+		if err := index.Delete(c.remove); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		expectMissing(t, index, c.remove)
+		expectGet(t, index, c.keep, i)
+	}
+}
+
+func testEmptyKey(t *testing.T, index onetable.Index) {
+	insert(t, index, "", 1)
+	insert(t, index, "a", 2)
+	expectGet(t, index, "", 1)
+
+	items, err := index.Between("", "a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Between with empty key", items, []string{"", "a"})
+
+	if err := index.Delete(""); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectMissing(t, index, "")
+}
+
+func testBetween(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e", "ab"} {
+		insert(t, index, key, i)
+	}
+
+	cases := []struct {
+		from, to string
+		expected []string
+	}{
+		{"c", "d", []string{"c", "c0", "c1", "c2", "d"}},
+		{"a", "b", []string{"a", "ab", "b"}},
+		{"aa", "c05", []string{"ab", "b", "c", "c0"}},
+		{"", "\xff", []string{"a", "ab", "b", "c", "c0", "c1", "c2", "d", "e"}},
+		{"f", "z", nil},
+		{"d", "c", nil},
+	}
+
+	for _, c := range cases {
+		items, err := index.Between(c.from, c.to)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, fmt.Sprintf("Between(%q, %q)", c.from, c.to), items, c.expected)
+	}
+}
+
+func testAscend(t *testing.T, index onetable.Index) {
+	keys := []string{"d", "a", "b", "f", "c"}
+	for i, key := range keys {
+		insert(t, index, key, i)
+	}
+
+	var items []*onetable.Item
+	err := index.Ascend(func(item *onetable.Item) bool {
+		items = append(items, item)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Ascend", items, []string{"a", "b", "c", "d", "f"})
+
+	items = nil
+	err = index.Ascend(func(item *onetable.Item) bool {
+		items = append(items, item)
+		return len(items) < 2
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Ascend stopped after 2 items", items, []string{"a", "b"})
+}
+
+func testAscendRange(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e", "ab"} {
+		insert(t, index, key, i)
+	}
+
+	cases := []struct {
+		from, to string
+		limit    int
+		expected []string
+	}{
+		{"c", "d", 10, []string{"c", "c0", "c1", "c2", "d"}},
+		{"c", "d", 2, []string{"c", "c0"}},
+		{"aa", "c05", 3, []string{"ab", "b", "c"}},
+		{"", "\xff", 1, []string{"a"}},
+		{"f", "z", 10, nil},
+		{"d", "c", 10, nil},
+	}
+
+	for _, c := range cases {
+		var items []*onetable.Item
+		err := index.(onetable.RangeIndex).AscendRange(c.from, c.to, func(item *onetable.Item) bool {
+			items = append(items, item)
+			return len(items) < c.limit
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, fmt.Sprintf("AscendRange(%q, %q) of %d items", c.from, c.to, c.limit), items, c.expected)
+	}
+}
+
+func testDescendRange(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e", "ab"} {
+		insert(t, index, key, i)
+	}
+
+	cases := []struct {
+		from, to string
+		limit    int
+		expected []string
+	}{
+		{"c", "d", 10, []string{"d", "c2", "c1", "c0", "c"}},
+		{"c", "d", 2, []string{"d", "c2"}},
+		{"aa", "c05", 3, []string{"c0", "c", "b"}},
+		{"", "\xff", 1, []string{"e"}},
+		{"", "a", 10, []string{"a"}},
+		{"f", "z", 10, nil},
+		{"d", "c", 10, nil},
+	}
+
+	for _, c := range cases {
+		var items []*onetable.Item
+		err := index.(onetable.RangeIndex).DescendRange(c.from, c.to, func(item *onetable.Item) bool {
+			items = append(items, item)
+			return len(items) < c.limit
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, fmt.Sprintf("DescendRange(%q, %q) of %d items", c.from, c.to, c.limit), items, c.expected)
+	}
+}
+
+func testBetweenRange(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "e", "ab"} {
+		insert(t, index, key, i)
+	}
+
+	incl, excl := onetable.Incl, onetable.Excl
+	unbounded := onetable.Bound{}
+
+	cases := []struct {
+		start, end onetable.Bound
+		expected   []string
+	}{
+		{incl("c"), incl("d"), []string{"c", "c0", "c1", "c2", "d"}},
+		{excl("c"), incl("d"), []string{"c0", "c1", "c2", "d"}},
+		{incl("c"), excl("d"), []string{"c", "c0", "c1", "c2"}},
+		{excl("c"), excl("c0"), nil},
+		{unbounded, excl("b"), []string{"a", "ab"}},
+		{excl("c2"), unbounded, []string{"d", "e"}},
+		{unbounded, unbounded, []string{"a", "ab", "b", "c", "c0", "c1", "c2", "d", "e"}},
+		{incl("d"), incl("c"), nil},
+	}
+
+	for _, c := range cases {
+		items, err := index.(onetable.BoundedIndex).BetweenRange(onetable.Range{Start: c.start, End: c.end})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, fmt.Sprintf("BetweenRange(%+v, %+v)", c.start, c.end), items, c.expected)
+	}
+}
+
+func testAscendPrefix(t *testing.T, index onetable.Index) {
+	for i, key := range []string{"a", "b", "c1", "c0", "c2", "c", "d", "c\xff", "c\xff\xff", "ca"} {
+		insert(t, index, key, i)
+	}
+
+	cases := []struct {
+		prefix, from string
+		limit        int
+		expected     []string
+	}{
+		{"c", "", 10, []string{"c", "c0", "c1", "c2", "ca", "c\xff", "c\xff\xff"}},
+		{"c", "", 2, []string{"c", "c0"}},
+		{"c", "c1", 3, []string{"c1", "c2", "ca"}},
+		{"c\xff", "", 10, []string{"c\xff", "c\xff\xff"}},
+		{"c", "d", 10, nil},
+		{"", "c2", 3, []string{"c2", "ca", "c\xff"}},
+		{"x", "", 10, nil},
+	}
+
+	for _, c := range cases {
+		var items []*onetable.Item
+		err := index.(onetable.PrefixIndex).AscendPrefix(c.prefix, c.from, func(item *onetable.Item) bool {
+			items = append(items, item)
+			return len(items) < c.limit
+		})
+		if errors.Is(err, onetable.ErrFullScan) {
+			t.Skip("Index needs a full scan for prefixes")
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, fmt.Sprintf("AscendPrefix(%q, %q) of %d items", c.prefix, c.from, c.limit), items, c.expected)
+	}
+}
+
+func testRandomOperations(t *testing.T, index onetable.Index) {
+	rng := rand.New(rand.NewSource(1))
+	expected := map[string]int{}
+
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%x", rng.Intn(1<<16))
+	}
+
+	for i := 0; i < 5000; i++ {
+		key := keys[rng.Intn(len(keys))]
+
+		if rng.Intn(3) == 0 {
+			if err := index.Delete(key); err != nil {
+				t.Fatal(err.Error())
+			}
+			delete(expected, key)
+		} else {
+			insert(t, index, key, i)
+			expected[key] = i
+		}
+	}
+
+	sorted := make([]string, 0, len(expected))
+	for key, i := range expected {
+		expectGet(t, index, key, i)
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var items []*onetable.Item
+	index.Ascend(func(item *onetable.Item) bool {
+		items = append(items, item)
+		return true
+	})
+	expectKeys(t, "Ascend", items, sorted)
+
+	items, err := index.Between(sorted[10], sorted[len(sorted)-10])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectKeys(t, "Between", items, sorted[10:len(sorted)-9])
+
+	if ri, ok := index.(onetable.RangeIndex); ok {
+		reversed := slices.Clone(sorted[10 : len(sorted)-9])
+		slices.Reverse(reversed)
+
+		items = nil
+		err := ri.DescendRange(sorted[10], sorted[len(sorted)-10], func(item *onetable.Item) bool {
+			items = append(items, item)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		expectKeys(t, "DescendRange", items, reversed)
+	}
+}
@@ -0,0 +1,145 @@
+package onetable
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+var unusualKeys = [][]byte{
+	{},
+	[]byte("a,b"),
+	[]byte("line\nbreak"),
+	[]byte("carriage\r\nreturn"),
+	[]byte(`"quoted"`),
+	[]byte(" leading space"),
+	{0x00},
+	{0x00, 0xff, 0xfe},
+	{0xff},
+	[]byte("tenant/é"),
+	{0xff, 'a', 0xff},
+}
+
+func TestKeysArbitraryBytes(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBTree(2))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i, key := range unusualKeys {
+		if err := table.InsertBytes(key, []byte{byte(i)}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	reopened, err := New(folder, NewIndexART())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i, key := range unusualKeys {
+		v, err := reopened.GetBytes(key)
+		if err != nil || !bytes.Equal(v, []byte{byte(i)}) {
+			t.Fatalf("Key %q: Expected %v, Got %v (%v)", key, []byte{byte(i)}, v, err)
+		}
+	}
+
+	items, err := reopened.BetweenBytes([]byte{}, []byte{0xff, 0xff})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != len(unusualKeys) {
+		t.Fatalf("Expected %d items, Got %d", len(unusualKeys), len(items))
+	}
+
+	for i := 1; i < len(items); i++ {
+		if bytes.Compare([]byte(items[i-1].Key), []byte(items[i].Key)) >= 0 {
+			t.Fatalf("Keys not ordered bytewise: %q, %q", items[i-1].Key, items[i].Key)
+		}
+	}
+
+	if err := reopened.DeleteBytes([]byte{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v, _ := reopened.GetBytes([]byte{}); v != nil {
+		t.Fatal("Empty key found after delete")
+	}
+}
+
+func TestKeysLegacyFormat(t *testing.T) {
+	folder := t.TempDir()
+
+	os.WriteFile(path.Join(folder, dataFileName), []byte{}, 0644)
+	os.WriteFile(path.Join(folder, indexFileName), []byte{}, 0644)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range []string{"", "a,b", `"quoted"`, " leading space", "\x00\xff"} {
+		if err := table.Insert(key, []byte(key)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err := table.Insert("line\nbreak", []byte("x")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Expected ErrInvalidKey, Got %v", err)
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	compareTables(t, table, reopened)
+
+	if err := reopened.Upgrade(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := reopened.Insert("line\nbreak", []byte("x")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestKeysDeleteArbitraryBytes(t *testing.T) {
+	for name, newIndex := range map[string]func() Index{
+		"ART":   func() Index { return NewIndexART() },
+		"BTree": func() Index { return NewIndexBTree(2) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i, key := range unusualKeys {
+				table.InsertBytes(key, []byte{byte(i)})
+			}
+
+			// delete the keys one by one, the others must stay reachable
+			for i, deleted := range unusualKeys {
+				if err := table.DeleteBytes(deleted); err != nil {
+					t.Fatal(err.Error())
+				}
+
+				if table.HasBytes(deleted) {
+					t.Fatalf("Key %q found after delete", deleted)
+				}
+
+				for j := i + 1; j < len(unusualKeys); j++ {
+					v, err := table.GetBytes(unusualKeys[j])
+					if err != nil || !bytes.Equal(v, []byte{byte(j)}) {
+						t.Fatalf("Key %q after deleting %q: Expected %v, Got %v (%v)", unusualKeys[j], deleted, []byte{byte(j)}, v, err)
+					}
+				}
+			}
+		})
+	}
+}
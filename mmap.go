@@ -0,0 +1,71 @@
+package onetable
+
+// readMapped copies a value out of the memory mapped data file. The file is
+// remapped when the value lies past the end of the current mapping, which
+// happens after the file grew since the last read.
+func (o *OneTable) readMapped(offset int64, length int) ([]byte, bool, error) {
+	end := offset + int64(length)
+
+	o.mmapLock.RLock()
+	if end > int64(len(o.mmap)) {
+		o.mmapLock.RUnlock()
+
+		if err := o.remap(end); err != nil {
+			return nil, false, err
+		}
+
+		o.mmapLock.RLock()
+	}
+	defer o.mmapLock.RUnlock()
+
+	if end > int64(len(o.mmap)) {
+		return nil, false, nil
+	}
+
+	b := make([]byte, length)
+	copy(b, o.mmap[offset:end])
+
+	return b, true, nil
+}
+
+// remap maps the whole data file, unless another reader already mapped at
+// least up to end
+func (o *OneTable) remap(end int64) error {
+	o.mmapLock.Lock()
+	defer o.mmapLock.Unlock()
+
+	if end <= int64(len(o.mmap)) {
+		return nil
+	}
+
+	info, err := o.dataFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	mapped, err := mmapFile(o.dataFile, info.Size())
+	if err != nil {
+		return err
+	}
+
+	if err := munmap(o.mmap); err != nil {
+		munmap(mapped)
+		return err
+	}
+
+	o.mmap = mapped
+
+	return nil
+}
+
+// unmap drops the mapping of the data file. Callers must hold fileLock for
+// writing, so that no reader is using the mapping.
+func (o *OneTable) unmap() error {
+	o.mmapLock.Lock()
+	defer o.mmapLock.Unlock()
+
+	err := munmap(o.mmap)
+	o.mmap = nil
+
+	return err
+}
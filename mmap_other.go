@@ -0,0 +1,18 @@
+//go:build !unix
+
+package onetable
+
+import (
+	"errors"
+	"os"
+)
+
+const mmapSupported = false
+
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("Memory mapped files are not supported on this platform")
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMmapReadsGrowingFile(t *testing.T) {
+	table, err := NewWithOptions(t.TempDir(), NewIndexBST(), Options{Mmap: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if v, _ := table.Get("missing"); v != nil {
+		t.Fatal("Found value in empty table")
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		if err := table.Insert(key, []byte("value"+key)); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// every insert grows the file past the current mapping
+		v, err := table.Get(key)
+		if err != nil || string(v) != "value"+key {
+			t.Fatalf("Expected %s, Got %s (%v)", "value"+key, v, err)
+		}
+	}
+
+	items, err := table.Between("key010", "key019")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != 10 || string(items[0].Value) != "valuekey010" {
+		t.Fatalf("Unexpected between result of %d items", len(items))
+	}
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := table.Get("key050")
+	if err != nil || string(v) != "valuekey050" {
+		t.Fatalf("Expected valuekey050 after compaction, Got %s (%v)", v, err)
+	}
+}
+
+func TestMmapDetectsCorruption(t *testing.T) {
+	folder := t.TempDir()
+	table, err := NewWithOptions(folder, NewIndexHashTable(), Options{Mmap: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	table.Insert("a", []byte("val a"))
+
+	f, _ := os.OpenFile(path.Join(folder, dataFileName), os.O_WRONLY, 0644)
+	f.WriteAt([]byte("X"), headerSize)
+	f.Close()
+
+	if _, err := table.Get("a"); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted, Got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package onetable
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const mmapSupported = true
+
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	// on 32 bit platforms large files do not fit into the address space
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("Data file of %d bytes is too large to map on this platform", size)
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(b []byte) error {
+	if b == nil {
+		return nil
+	}
+
+	return syscall.Munmap(b)
+}
@@ -1,41 +1,16 @@
 package onetable
 
 import (
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 )
 
-type typeOffset int
-
-type ValueMetadata interface {
-	Offset() typeOffset
-	Length() int
-}
-
-type valueMetadata struct {
-	offset typeOffset
-	length int
-}
-
-func (v valueMetadata) Offset() typeOffset {
-	return v.offset
-}
-
-func (v valueMetadata) Length() int {
-	return v.length
-}
-
-type item struct {
-	Key   string
-	Value ValueMetadata
-}
+type typeOffset int64
 
 type RangeItem struct {
 	Key   string
@@ -44,12 +19,15 @@ type RangeItem struct {
 
 const tombstone int = -1
 
-type Index interface {
-	get(key string) (ValueMetadata, bool)
-	insert(key string, value ValueMetadata) error
-	delete(key string) error
-	between(fromKey string, toKey string) ([]*item, error)
-}
+var ErrNotFound = errors.New("Key not found")
+
+// ErrFolderMissing is returned by New when the folder does not exist and
+// Options.CreateFolder is not set
+var ErrFolderMissing = errors.New("Folder does not exist")
+
+// ErrIndexMissing is returned by New when the folder holds a data file but
+// no index file
+var ErrIndexMissing = errors.New("Index does not exist for data")
 
 const (
 	dataFileName  string = "data.ot"
@@ -57,243 +35,543 @@ const (
 )
 
 type OneTable struct {
-	Path      string
-	Index     Index
-	lock      sync.Mutex
+	Path  string
+	Index Index
+	lock  sync.Mutex
+	// fileLock guards the data and index files against being swapped
+	// by Compact while readers are using offsets from the index
+	fileLock  sync.RWMutex
 	offset    typeOffset
 	dataPath  string
 	indexPath string
+	// dataFile and indexFile stay open for the lifetime of the table and
+	// are replaced by Compact, guarded by fileLock
+	dataFile  *os.File
+	indexFile *os.File
+	format    fileFormat
+	// mmap maps the data file when Options.Mmap is set. It is remapped
+	// as the file grows, guarded by mmapLock
+	mmap     []byte
+	mmapLock sync.RWMutex
+	recovery RecoveryReport
+	options  Options
+	// records is the number of records in the index file, guarded by lock
+	records int
+	// snapshotLock serializes Snapshot and Compact
+	snapshotLock sync.Mutex
+	// written is the sequence number of the last write, guarded by lock
+	written uint64
+	// version is the last key version given out, guarded by lock
+	version uint64
+	// halfSwapped is the error that stopped Compact between swapping the
+	// data and the index file, guarded by lock. Writes return it.
+	halfSwapped error
+	// syncLock serializes flushes and guards synced, syncs and syncErr
+	syncLock sync.Mutex
+	synced   uint64
+	// syncs counts the flushes done for SyncAlways writes
+	syncs    uint64
+	syncErr  error
+	syncStop chan struct{}
+	syncDone chan struct{}
+	// compactions counts the file swaps done by Compact, guarded by
+	// fileLock
+	compactions uint64
+	// txLock is held for reading by open transactions and for writing by
+	// Compact, which would invalidate the offsets of their snapshots
+	txLock sync.RWMutex
+	// txSeqs counts the open transactions by the sequence number of their
+	// snapshot and history keeps the values overwritten since the oldest
+	// one, both guarded by lock
+	txSeqs  map[uint64]int
+	history map[string][]version
+	// keys, liveBytes and tombstones are the running totals reported by
+	// Stats, guarded by lock. tombstones counts those in the index file.
+	keys         int
+	liveBytes    int64
+	tombstones   int
+	loadDuration time.Duration
 }
 
-func (o *OneTable) fillIndex(indexPath string) error {
+// logPosition is a point in the index file, together with the number of
+// records and tombstones before it, the end of the data file they reference
+// and the last key version they used
+type logPosition struct {
+	indexEnd   int64
+	dataEnd    int64
+	version    uint64
+	records    int
+	tombstones int
+}
+
+// fillIndex replays the index file from position from up to size bytes into
+// the Index. Replay stops at the first record pointing past dataSize, as its
+// value never made it to the data file. It returns the position up to which
+// records were applied.
+func (o *OneTable) fillIndex(indexPath string, from logPosition, size int64, dataSize int64) (logPosition, error) {
 	f, err := os.Open(indexPath)
 	if err != nil {
-		return err
+		return from, err
 	}
 
 	defer f.Close()
 
-	r := csv.NewReader(f)
+	if _, err := f.Seek(from.indexEnd, io.SeekStart); err != nil {
+		return from, err
+	}
+
+	r := o.format.newRecordReader(io.LimitReader(f, size-from.indexEnd), from.records)
+	pos := from
 
-	idx := 1
 	for {
-		record, err := r.Read()
+		rec, n, err := r.next()
 		if err == io.EOF {
 			break
 		}
 
-		if len(record) != 3 {
-			return fmt.Errorf("Invalid record at line %d. Does not contain 3 separated fields", idx)
+		if err != nil {
+			return pos, err
 		}
 
-		idx += 1
+		// a batch is applied only if all of its values are present
+		entries := rec.entries()
+		dataEnd := pos.dataEnd
+		for _, entry := range entries {
+			if !entry.tombstone {
+				dataEnd = max(dataEnd, int64(entry.valueMeta.offset)+int64(entry.valueMeta.length))
+			}
+		}
 
-		key := string(record[0])
-		var offset typeOffset
-		var offsetRaw, length int
+		if dataEnd > dataSize {
+			break
+		}
 
-		if offsetRaw, err = strconv.Atoi(record[1]); err != nil {
-			return fmt.Errorf("Invalid record at line %d. Offset %s is not an integer", idx, record[1])
+		for _, entry := range entries {
+			// records without a version get the next one
+			if entry.valueMeta.version == 0 {
+				entry.valueMeta.version = pos.version + 1
+			}
+			pos.version = max(pos.version, entry.valueMeta.version)
+
+			if entry.tombstone {
+				o.indexDelete(entry.key)
+				pos.tombstones++
+			} else {
+				o.indexInsert(entry.key, entry.valueMeta)
+			}
 		}
 
-		offset = typeOffset(offsetRaw)
+		pos.dataEnd = dataEnd
 
-		if length, err = strconv.Atoi(record[2]); err != nil {
-			return fmt.Errorf("Invalid record at line %d. Length %s is not an integer", idx, record[2])
-		}
+		pos.indexEnd = from.indexEnd + n
+		pos.records++
+	}
+	return pos, nil
+}
 
-		if length == tombstone {
-			o.Index.delete(key)
-			continue
-		}
+// createFile writes content to filePath so that after a crash the file is
+// either missing or complete. The content is flushed to a temporary file,
+// which is then renamed into place, and the folder is synced.
+func createFile(filePath string, content []byte) error {
+	tmpPath := filePath + ".tmp"
 
-		o.Index.insert(key, valueMetadata{offset: offset, length: length})
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer os.Remove(tmpPath)
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+
+	return syncDir(path.Dir(filePath))
 }
 
 func (o *OneTable) loadData() error {
 	dataPath := path.Join(o.Path, dataFileName)
 	indexPath := path.Join(o.Path, indexFileName)
 
-	// if data exists and index does not, panic
+	// finish or discard a compaction interrupted by a crash
+	if err := recoverCompaction(dataPath, indexPath); err != nil {
+		return err
+	}
+
 	_, dataFileErr := os.Stat(dataPath)
 	_, indexFileErr := os.Stat(indexPath)
 
-	if os.IsExist(dataFileErr) && os.IsNotExist(indexFileErr) {
-		return errors.New("Index does not exist for data")
+	if dataFileErr != nil && !os.IsNotExist(dataFileErr) {
+		return dataFileErr
+	}
+
+	if dataFileErr == nil && os.IsNotExist(indexFileErr) {
+		return fmt.Errorf("%w: %s", ErrIndexMissing, indexPath)
 	}
 
 	// if data file does not exist, create new files
 	if os.IsNotExist(dataFileErr) {
-		if _, err := os.Stat(indexPath); os.IsExist(err) {
-			os.Remove(indexPath)
+		// the index file is written first, its header tells the format.
+		// Until the data file exists the table counts as not created.
+		err := createFile(indexPath, formatBinary.header(indexMagic, 0))
+		if err != nil {
+			return err
 		}
 
-		err := os.WriteFile(dataPath, []byte{}, 0644)
+		err = createFile(dataPath, formatBinary.header(dataMagic, 0))
 		if err != nil {
 			return err
 		}
+	}
 
-		err = os.WriteFile(indexPath, []byte{}, 0644)
+	format, err := detectFormat(indexPath)
+	if err != nil {
+		return err
+	}
+	o.format = format
+
+	if format.binary() {
+		version, err := readHeader(dataPath, dataMagic)
 		if err != nil {
 			return err
 		}
+
+		if version == 0 {
+			return fmt.Errorf("Data file %s does not start with a valid header", dataPath)
+		}
 	}
 
-	err := o.fillIndex(indexPath)
+	indexSize, complete, err := completeIndexSize(indexPath, format)
 	if err != nil {
-		panic(err.Error())
+		return err
+	}
+
+	dataFile, err := os.Stat(dataPath)
+	if err != nil {
+		return err
+	}
+
+	// start from the latest snapshot of the index if there is a usable one.
+	// Replay continues past the end of a loaded hint, so the truncation
+	// below never cuts into it, and an unusable hint is removed.
+	from, err := o.loadHint(path.Join(o.Path, hintFileName), indexPath, complete, dataFile.Size())
+	if err != nil {
+		return err
+	}
+
+	if from.indexEnd == 0 {
+		from = logPosition{indexEnd: format.indexStart(), dataEnd: format.dataStart()}
+
+		if format == formatBinary {
+			if from.version, err = readBaseVersion(indexPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	pos, err := o.fillIndex(indexPath, from, complete, dataFile.Size())
+	if err != nil {
+		return err
+	}
+
+	o.recovery, err = truncateToConsistent(dataPath, indexPath, dataFile.Size(), pos.dataEnd, indexSize, pos.indexEnd)
+	if err != nil {
+		return err
 	}
 
 	o.dataPath = dataPath
 	o.indexPath = indexPath
+	o.offset = typeOffset(pos.dataEnd)
+	o.records = pos.records
+	o.tombstones = pos.tombstones
+	o.version = pos.version
 
-	dataFile, err := os.Stat(dataPath)
+	return o.openFiles()
+}
+
+// openFiles opens the long-lived handles to the data and index files
+func (o *OneTable) openFiles() error {
+	dataFile, err := os.OpenFile(o.dataPath, os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	indexFile, err := os.OpenFile(o.indexPath, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
+		dataFile.Close()
 		return err
 	}
 
-	o.offset = typeOffset(dataFile.Size())
+	o.dataFile = dataFile
+	o.indexFile = indexFile
 
 	return nil
 }
 
+func (o *OneTable) closeFiles() error {
+	dataErr := o.dataFile.Close()
+	indexErr := o.indexFile.Close()
+
+	return errors.Join(dataErr, indexErr)
+}
+
 func New(folderPath string, index Index) (*OneTable, error) {
-	o := &OneTable{Path: folderPath, Index: index}
+	return NewWithOptions(folderPath, index, Options{})
+}
+
+func NewWithOptions(folderPath string, index Index, options Options) (*OneTable, error) {
+	o := &OneTable{Path: folderPath, Index: index, options: options}
 
 	// check if path to data folder exists
-	if _, err := os.Stat(folderPath); os.IsNotExist(err) {
-		if err != nil {
-			panic(err.Error())
+	info, err := os.Stat(folderPath)
+	switch {
+	case os.IsNotExist(err) && options.CreateFolder:
+		if err := os.MkdirAll(folderPath, 0755); err != nil {
+			return nil, err
 		}
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("%w: %s", ErrFolderMissing, folderPath)
+	case err != nil:
+		return nil, err
+	case !info.IsDir():
+		return nil, fmt.Errorf("%s is not a folder", folderPath)
 	}
 
 	// if there is data at dataPath, populate the inmemory index
-	err := o.loadData()
-
-	if err != nil {
-		panic(err.Error())
+	start := time.Now()
+	if err := o.loadData(); err != nil {
+		return nil, err
 	}
+	o.loadDuration = time.Since(start)
 
-	return o, nil
-}
+	if options.Sync == SyncInterval {
+		interval := options.SyncInterval
+		if interval <= 0 {
+			interval = defaultSyncInterval
+		}
 
-func validateKey(key string) error {
-	if strings.Contains(string(key), "\n") || strings.Contains(string(key), ",") {
-		return errors.New("Invalid key. Contains one of forbidden characters: '\\n' or ','")
+		o.syncStop = make(chan struct{})
+		o.syncDone = make(chan struct{})
+		go o.syncPeriodically(interval)
 	}
 
-	return nil
+	return o, nil
 }
 
 func (o *OneTable) writeValue(value []byte) error {
-	f, err := os.OpenFile(o.dataPath, os.O_APPEND|os.O_WRONLY, 0644)
+	_, err := o.dataFile.Write(value)
+
 	if err != nil {
+		o.discardValues()
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.Write(value)
+	return nil
+}
 
-	if err != nil {
-		return err
+// discardValues cuts the data file back to offset after values were written
+// without their index record, so that the next value lands at offset. If the
+// file cannot be cut, offset moves past the orphaned bytes instead.
+func (o *OneTable) discardValues() {
+	if err := o.dataFile.Truncate(int64(o.offset)); err == nil {
+		return
 	}
 
-	return nil
+	if info, err := o.dataFile.Stat(); err == nil {
+		o.offset = typeOffset(info.Size())
+	}
 }
 
-func (o *OneTable) writeKey(key string, valueMeta valueMetadata) error {
-	f, err := os.OpenFile(o.indexPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
+func (o *OneTable) writeRecord(rec indexRecord) error {
+	if _, err := o.indexFile.Write(o.format.appendRecord(nil, rec)); err != nil {
 		return err
 	}
-	defer f.Close()
-
-	w := csv.NewWriter(f)
-	w.Write([]string{
-		string(key),
-		strconv.Itoa(int(valueMeta.offset)),
-		strconv.Itoa(valueMeta.length),
-	})
 
-	w.Flush()
+	o.records++
 
 	return nil
 }
 
+// Insert stores value under key. Keys are arbitrary byte strings, including
+// the empty one.
 func (o *OneTable) Insert(key string, value []byte) error {
-	err := validateKey(key)
+	seq, err := o.appendInsert(key, value)
 	if err != nil {
 		return err
 	}
 
+	return o.commit(seq)
+}
+
+func (o *OneTable) appendInsert(key string, value []byte) (uint64, error) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
 
+	return o.appendInsertLocked(key, value)
+}
+
+// appendInsertLocked writes the value and its index record with lock held
+func (o *OneTable) appendInsertLocked(key string, value []byte) (uint64, error) {
+	if o.halfSwapped != nil {
+		return 0, o.halfSwapped
+	}
+
+	err := o.format.validateKey(key)
+	if err != nil {
+		return 0, err
+	}
+
 	err = o.writeValue(value)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	valueMeta := valueMetadata{offset: o.offset, length: len(value)}
+	o.version++
+	valueMeta := valueMetadata{
+		offset:      o.offset,
+		length:      len(value),
+		checksum:    checksum(value),
+		checksummed: true,
+		version:     o.version,
+	}
 
-	err = o.writeKey(key, valueMeta)
+	err = o.writeRecord(indexRecord{key: key, valueMeta: valueMeta})
 	if err != nil {
-		return err
+		o.discardValues()
+		return 0, err
 	}
 
-	o.Index.insert(key, valueMeta)
+	o.recordHistory(key)
+	o.indexInsert(key, valueMeta)
 	o.offset = o.offset + typeOffset(len(value))
+	o.written++
 
-	return nil
+	return o.written, nil
 }
 
-func (o *OneTable) readValue(offset typeOffset, length int) ([]byte, error) {
+func (o *OneTable) readValue(key string, valueMeta ValueMetadata) ([]byte, error) {
+	if o.options.Mmap && mmapSupported {
+		b, ok, err := o.readMapped(valueMeta.Offset(), valueMeta.Length())
+		if err != nil {
+			return nil, err
+		}
 
-	f, err := os.Open(o.dataPath)
-	if err != nil {
-		return nil, err
+		if !ok {
+			return nil, &CorruptedError{Key: key, Offset: valueMeta.Offset()}
+		}
+
+		return b, verifyChecksum(key, valueMeta, b)
 	}
 
-	defer f.Close()
+	b := make([]byte, valueMeta.Length())
+	if _, err := o.dataFile.ReadAt(b, valueMeta.Offset()); err != nil {
+		if err == io.EOF {
+			return nil, &CorruptedError{Key: key, Offset: valueMeta.Offset()}
+		}
+		return nil, err
+	}
 
-	b := make([]byte, length)
-	f.ReadAt(b, int64(offset))
+	if err := verifyChecksum(key, valueMeta, b); err != nil {
+		return nil, err
+	}
 
 	return b, nil
-
 }
 
+// Get returns the value stored under key, or ErrNotFound if there is none.
+// A value inserted empty is returned as an empty, non nil slice.
 func (o *OneTable) Get(key string) ([]byte, error) {
-	valueMeta, found := o.Index.get(key)
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	valueMeta, found := o.Index.Get(key)
 
 	if !found {
-		return nil, nil
+		return nil, ErrNotFound
 	}
 
-	return o.readValue(valueMeta.Offset(), valueMeta.Length())
+	return o.readValue(key, valueMeta)
+}
+
+// Has reports whether key is present. Unlike Get it only consults the
+// Index and does not read the data file.
+func (o *OneTable) Has(key string) bool {
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	_, found := o.Index.Get(key)
+
+	return found
 }
 
 func (o *OneTable) Delete(key string) error {
+	seq, err := o.appendDelete(key)
+	if err != nil {
+		return err
+	}
+
+	return o.commit(seq)
+}
+
+func (o *OneTable) appendDelete(key string) (uint64, error) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
 
-	o.writeKey(key, valueMetadata{offset: -1, length: tombstone})
-	return o.Index.delete(key)
+	return o.appendDeleteLocked(key)
 }
 
+// appendDeleteLocked writes a tombstone with lock held
+func (o *OneTable) appendDeleteLocked(key string) (uint64, error) {
+	if o.halfSwapped != nil {
+		return 0, o.halfSwapped
+	}
+
+	if err := o.format.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	err := o.writeRecord(indexRecord{key: key, tombstone: true, valueMeta: valueMetadata{version: o.version + 1}})
+	if err != nil {
+		return 0, err
+	}
+
+	o.recordHistory(key)
+	o.tombstones++
+	o.version++
+	o.written++
+
+	return o.written, o.indexDelete(key)
+}
+
+// Between returns the items with keys from fromKey to toKey inclusive. Keys
+// are ordered bytewise.
 func (o *OneTable) Between(fromKey string, toKey string) ([]*RangeItem, error) {
-	items, err := o.Index.between(fromKey, toKey)
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	items, err := o.Index.Between(fromKey, toKey)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return o.readItems(items)
+}
+
+// readItems reads the values of items. It must be called with fileLock held.
+func (o *OneTable) readItems(items []*Item) ([]*RangeItem, error) {
 	ritems := make([]*RangeItem, len(items))
 
 	for i := 0; i < len(items); i++ {
-		v, err := o.readValue(items[i].Value.Offset(), items[i].Value.Length())
+		v, err := o.readValue(items[i].Key, items[i].Value)
 		if err != nil {
 			return nil, err
 		}
@@ -303,3 +581,29 @@ func (o *OneTable) Between(fromKey string, toKey string) ([]*RangeItem, error) {
 
 	return ritems, nil
 }
+
+// InsertBytes is Insert with a []byte key
+func (o *OneTable) InsertBytes(key []byte, value []byte) error {
+	return o.Insert(string(key), value)
+}
+
+// GetBytes is Get with a []byte key
+func (o *OneTable) GetBytes(key []byte) ([]byte, error) {
+	return o.Get(string(key))
+}
+
+// HasBytes is Has with a []byte key
+func (o *OneTable) HasBytes(key []byte) bool {
+	return o.Has(string(key))
+}
+
+// DeleteBytes is Delete with a []byte key
+func (o *OneTable) DeleteBytes(key []byte) error {
+	return o.Delete(string(key))
+}
+
+// BetweenBytes is Between with []byte keys. The keys of the returned items
+// hold the raw key bytes.
+func (o *OneTable) BetweenBytes(fromKey []byte, toKey []byte) ([]*RangeItem, error) {
+	return o.Between(string(fromKey), string(toKey))
+}
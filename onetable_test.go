@@ -0,0 +1,146 @@
+package onetable
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestGetNotFound(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := table.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, Got %v", err)
+	}
+
+	if table.Has("missing") {
+		t.Fatal("Has reports a missing key")
+	}
+
+	if err := table.Insert("empty", []byte{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := table.Get("empty")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v == nil || len(v) != 0 {
+		t.Fatalf("Expected an empty value, Got %v", v)
+	}
+
+	if !table.Has("empty") {
+		t.Fatal("Has does not report an empty value")
+	}
+
+	table.Delete("empty")
+
+	if _, err := table.Get("empty"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound after delete, Got %v", err)
+	}
+
+	if table.Has("empty") {
+		t.Fatal("Has reports a deleted key")
+	}
+}
+
+func TestGetEmptyValueMmap(t *testing.T) {
+	table, err := NewWithOptions(t.TempDir(), NewIndexHashTable(), Options{Mmap: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	table.Insert("empty", []byte{})
+
+	v, err := table.Get("empty")
+	if err != nil || v == nil || len(v) != 0 {
+		t.Fatalf("Expected an empty value, Got %v (%v)", v, err)
+	}
+}
+
+func TestDeleteWriteError(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+
+	// writes to the index file fail from now on
+	table.indexFile.Close()
+
+	if err := table.Delete("a"); err == nil {
+		t.Fatal("Expected Delete to fail")
+	}
+
+	if ok, err := table.DeleteIfEquals("a", []byte("val a")); ok || err == nil {
+		t.Fatalf("Expected DeleteIfEquals to fail, Got %t (%v)", ok, err)
+	}
+
+	if !table.Has("a") {
+		t.Fatal("Key a removed by a delete that was not written")
+	}
+
+	if s := table.Stats(); s.Tombstones != 0 || s.Keys != 1 {
+		t.Fatalf("Unexpected stats after failed deletes: %+v", s)
+	}
+}
+
+func TestInsertAfterIndexWriteError(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+
+	// writes to the index file fail, while the value is still appended
+	indexFile := table.indexFile
+	table.indexFile, _ = os.Open(table.indexPath)
+
+	if err := table.Insert("b", []byte("val b")); err == nil {
+		t.Fatal("Expected Insert to fail")
+	}
+
+	var batch Batch
+	batch.Put("b", []byte("val b"))
+	if err := table.Write(&batch); err == nil {
+		t.Fatal("Expected Write to fail")
+	}
+
+	table.indexFile.Close()
+	table.indexFile = indexFile
+
+	if err := table.Insert("c", []byte("val c")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	batch.Reset()
+	batch.Put("d", []byte("val d"))
+	if err := table.Write(&batch); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, tb := range []*OneTable{table, reopened} {
+		for _, key := range []string{"a", "c", "d"} {
+			if v, err := tb.Get(key); string(v) != "val "+key {
+				t.Fatalf("Expected 'val %s', Got %s (%v)", key, v, err)
+			}
+		}
+
+		if tb.Has("b") {
+			t.Fatal("Key b found after its writes failed")
+		}
+	}
+}
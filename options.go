@@ -0,0 +1,22 @@
+package onetable
+
+import "time"
+
+const defaultSyncInterval = time.Second
+
+type Options struct {
+	Sync SyncMode
+	// SyncInterval is the flush period for SyncInterval mode.
+	// Defaults to one second.
+	SyncInterval time.Duration
+	// Mmap serves reads from a memory mapping of the data file instead of
+	// pread calls. Ignored on platforms without mmap.
+	Mmap bool
+	// SnapshotEvery writes an index snapshot after every SnapshotEvery
+	// writes, so that opening the table replays only the writes since.
+	// Zero disables periodic snapshots, Snapshot can still be called.
+	SnapshotEvery int
+	// CreateFolder creates the folder passed to NewWithOptions, including
+	// its parents, if it does not exist yet
+	CreateFolder bool
+}
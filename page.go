@@ -0,0 +1,72 @@
+package onetable
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+)
+
+// ErrInvalidToken is returned by BetweenPage for a token it did not issue
+var ErrInvalidToken = errors.New("Invalid page token")
+
+// pageTokenVersion starts every page token, so that a token for the empty
+// key is not empty and the encoding can change later
+const pageTokenVersion = 1
+
+// BetweenPage returns up to limit items with keys from fromKey to toKey
+// inclusive, and a token for the next page, which is empty after the last
+// one. Pass an empty token for the first page and the returned token, with
+// the same keys, for the following ones. A limit of 0 or less means no limit.
+//
+// A token holds the last key of its page, so the next page starts right
+// after it regardless of the writes in between, and tokens stay valid across
+// Compact and reopening the table. Tokens are URL safe.
+func (o *OneTable) BetweenPage(fromKey string, toKey string, limit int, token string) ([]*RangeItem, string, error) {
+	if token != "" {
+		last, err := decodePageToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		// the smallest key after it
+		fromKey = max(fromKey, last+"\x00")
+	}
+
+	if limit <= 0 || limit == math.MaxInt {
+		limit = math.MaxInt - 1
+	}
+
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	// one item more tells whether there is a next page
+	items, _, err := o.fetchRange(fromKey, toKey, limit+1, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(items) > limit {
+		items = items[:limit]
+		next = encodePageToken(items[limit-1].Key)
+	}
+
+	ritems, err := o.readItems(items)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ritems, next, nil
+}
+
+func encodePageToken(lastKey string) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{pageTokenVersion}, lastKey...))
+}
+
+func decodePageToken(token string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) == 0 || b[0] != pageTokenVersion {
+		return "", ErrInvalidToken
+	}
+
+	return string(b[1:]), nil
+}
@@ -0,0 +1,133 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBetweenPage(t *testing.T) {
+	indexes := map[string]func() Index{
+		"Hashtable": func() Index { return NewIndexHashTable() },
+		"BTree":     func() Index { return NewIndexBTree(2) },
+		"SkipList":  func() Index { return NewIndexSkipList() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i := 0; i < 250; i++ {
+				table.Insert(fmt.Sprintf("key%03d", i), []byte(fmt.Sprintf("value%d", i)))
+			}
+
+			var keys []string
+			token := ""
+			pages := 0
+			for {
+				items, next, err := table.BetweenPage("key000", "key249", 100, token)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+
+				for _, item := range items {
+					keys = append(keys, item.Key)
+				}
+				pages++
+
+				if next == "" {
+					break
+				}
+				token = next
+			}
+
+			if pages != 3 || len(keys) != 250 {
+				t.Fatalf("Expected 250 keys in 3 pages, Got %d keys in %d pages", len(keys), pages)
+			}
+
+			for i, key := range keys {
+				if key != fmt.Sprintf("key%03d", i) {
+					t.Fatalf("Expected key%03d, Got %s", i, key)
+				}
+			}
+		})
+	}
+}
+
+func TestBetweenPageExactLimit(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexAVL())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("val a"))
+	table.Insert("b", []byte("val b"))
+
+	items, next, err := table.BetweenPage("a", "z", 2, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != 2 || next != "" {
+		t.Fatalf("Expected 2 items and no next page, Got %d items and token %q", len(items), next)
+	}
+
+	items, _, _ = table.BetweenPage("a", "z", 0, "")
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items without a limit, Got %d", len(items))
+	}
+}
+
+func TestBetweenPageConcurrentWrites(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexART())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range []string{"", "b", "d", "f"} {
+		table.Insert(key, []byte("val"))
+	}
+
+	items, token, err := table.BetweenPage("", "z", 2, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != 2 || items[0].Key != "" || items[1].Key != "b" || token == "" {
+		t.Fatalf("Unexpected first page %v with token %q", items, token)
+	}
+
+	// keys before the resume point are not returned again, keys after are
+	table.Insert("a", []byte("val"))
+	table.Insert("c", []byte("val"))
+	table.Delete("d")
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	items, token, err = table.BetweenPage("", "z", 2, token)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != 2 || items[0].Key != "c" || items[1].Key != "f" || token != "" {
+		t.Fatalf("Unexpected second page %v with token %q", items, token)
+	}
+}
+
+func TestBetweenPageInvalidToken(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, token := range []string{"not base64!", "AA", "="} {
+		if _, _, err := table.BetweenPage("a", "z", 10, token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Expected ErrInvalidToken for %q, Got %v", token, err)
+		}
+	}
+}
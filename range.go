@@ -0,0 +1,125 @@
+package onetable
+
+// BoundKind tells how a Bound limits a Range
+type BoundKind int
+
+const (
+	// Unbounded leaves the range open on that side. It is the zero value,
+	// so Range{} covers every key.
+	Unbounded BoundKind = iota
+	// Inclusive includes the key of the bound in the range
+	Inclusive
+	// Exclusive stops the range right before or after the key of the bound
+	Exclusive
+)
+
+// Bound is one end of a Range
+type Bound struct {
+	Key  string
+	Kind BoundKind
+}
+
+// Incl returns an inclusive bound at key
+func Incl(key string) Bound {
+	return Bound{Key: key, Kind: Inclusive}
+}
+
+// Excl returns an exclusive bound at key
+func Excl(key string) Bound {
+	return Bound{Key: key, Kind: Exclusive}
+}
+
+// Range is a key range with inclusive, exclusive or unbounded ends. Half-open
+// ranges tile a keyspace without overlaps:
+//
+//	Range{Start: Incl("a"), End: Excl("m")}
+//	Range{Start: Incl("m"), End: Excl("t")}
+//	Range{Start: Incl("t")}
+type Range struct {
+	Start Bound
+	End   Bound
+}
+
+// Contains tells whether key lies within the range
+func (r Range) Contains(key string) bool {
+	return r.afterStart(key) && r.beforeEnd(key)
+}
+
+func (r Range) afterStart(key string) bool {
+	switch r.Start.Kind {
+	case Inclusive:
+		return key >= r.Start.Key
+	case Exclusive:
+		return key > r.Start.Key
+	}
+	return true
+}
+
+func (r Range) beforeEnd(key string) bool {
+	switch r.End.Kind {
+	case Inclusive:
+		return key <= r.End.Key
+	case Exclusive:
+		return key < r.End.Key
+	}
+	return true
+}
+
+// from returns the smallest key the range can contain
+func (r Range) from() string {
+	if r.Start.Kind == Exclusive {
+		return r.Start.Key + "\x00"
+	}
+	return r.Start.Key
+}
+
+// BoundedIndex is implemented by indexes that can return the items of a
+// Range directly. OneTable.BetweenRange falls back to Ascend for other
+// indexes.
+type BoundedIndex interface {
+	// BetweenRange returns the items with keys in r ordered by key
+	BetweenRange(r Range) ([]*Item, error)
+}
+
+// collectRange collects the items of r from walk, which visits the keys from
+// fromKey to toKey inclusive in key order, or with no upper bound if
+// unbounded is set, until fn returns false
+func collectRange(r Range, walk func(fromKey string, toKey string, unbounded bool, fn func(*Item) bool)) []*Item {
+	var res []*Item
+	walk(r.from(), r.End.Key, r.End.Kind == Unbounded, func(it *Item) bool {
+		// an exclusive end is walked to inclusively and stops here
+		if !r.beforeEnd(it.Key) {
+			return false
+		}
+		res = append(res, it)
+		return true
+	})
+	return res
+}
+
+// BetweenRange returns the items with keys in r ordered by key. Unlike
+// Between, either end can be exclusive or left open.
+func (o *OneTable) BetweenRange(r Range) ([]*RangeItem, error) {
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	var items []*Item
+	var err error
+
+	if bi, ok := o.Index.(BoundedIndex); ok {
+		items, err = bi.BetweenRange(r)
+	} else {
+		err = o.Index.Ascend(func(it *Item) bool {
+			if r.Contains(it.Key) {
+				items = append(items, it)
+			}
+			return r.beforeEnd(it.Key)
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return o.readItems(items)
+}
@@ -0,0 +1,96 @@
+package onetable
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBetweenRangeTiles(t *testing.T) {
+	indexes := map[string]func() Index{
+		"Hashtable": func() Index { return NewIndexHashTable() },
+		"BST":       func() Index { return NewIndexBST() },
+		"SkipList":  func() Index { return NewIndexSkipList() },
+	}
+
+	for name, newIndex := range indexes {
+		t.Run(name, func(t *testing.T) {
+			table, err := New(t.TempDir(), newIndex())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			for i := 0; i < 100; i++ {
+				table.Insert(fmt.Sprintf("key%02d", i), []byte(fmt.Sprintf("value%d", i)))
+			}
+			table.Insert("key\xff\xff", []byte("last"))
+
+			// non-overlapping chunks covering every key
+			tiles := []Range{
+				{End: Excl("key25")},
+				{Start: Incl("key25"), End: Excl("key50")},
+				{Start: Incl("key50"), End: Excl("key75")},
+				{Start: Incl("key75")},
+			}
+
+			var keys []string
+			for _, r := range tiles {
+				items, err := table.BetweenRange(r)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+
+				for _, item := range items {
+					keys = append(keys, item.Key)
+				}
+			}
+
+			if len(keys) != 101 {
+				t.Fatalf("Expected 101 keys, Got %d", len(keys))
+			}
+
+			for i := 0; i < 100; i++ {
+				if keys[i] != fmt.Sprintf("key%02d", i) {
+					t.Fatalf("Expected key%02d, Got %s", i, keys[i])
+				}
+			}
+
+			if keys[100] != "key\xff\xff" {
+				t.Fatalf("Expected the unbounded tile to end with key\\xff\\xff, Got %q", keys[100])
+			}
+		})
+	}
+}
+
+func TestBetweenRangeExclusiveStart(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexART())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range []string{"a", "a\x00", "b"} {
+		table.Insert(key, []byte("val "+key))
+	}
+
+	items, err := table.BetweenRange(Range{Start: Excl("a"), End: Incl("b")})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(items) != 2 || items[0].Key != "a\x00" || string(items[1].Value) != "val b" {
+		t.Fatalf("Unexpected items %v", items)
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	r := Range{Start: Excl("b"), End: Incl("d")}
+
+	for key, expected := range map[string]bool{"a": false, "b": false, "b0": true, "d": true, "d0": false} {
+		if r.Contains(key) != expected {
+			t.Fatalf("Expected Contains(%q) to be %t", key, expected)
+		}
+	}
+
+	if !(Range{}).Contains("") {
+		t.Fatal("Expected the empty range to contain every key")
+	}
+}
@@ -0,0 +1,102 @@
+package onetable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// RecoveryReport describes what New had to repair to bring the data and
+// index files back to a consistent state after an unclean shutdown.
+type RecoveryReport struct {
+	// IndexBytesTruncated is the number of bytes cut from the end of the
+	// index file, either a half-written record or records whose values are
+	// missing from the data file
+	IndexBytesTruncated int64
+	// DataBytesTruncated is the number of bytes cut from the end of the data
+	// file that no index record was pointing to
+	DataBytesTruncated int64
+}
+
+// Repaired reports whether any of the files had to be truncated
+func (r RecoveryReport) Repaired() bool {
+	return r.IndexBytesTruncated > 0 || r.DataBytesTruncated > 0
+}
+
+func (r RecoveryReport) String() string {
+	if !r.Repaired() {
+		return "No repair needed"
+	}
+
+	return fmt.Sprintf("Truncated %d bytes of index and %d bytes of data", r.IndexBytesTruncated, r.DataBytesTruncated)
+}
+
+// Recovery returns what was repaired when the table was opened
+func (o *OneTable) Recovery() RecoveryReport {
+	return o.recovery
+}
+
+// completeIndexSize returns the size of the index file and the position up to
+// which it may contain complete records. In the legacy format that is right
+// after the last newline, as every record is appended in a single write
+// ending with a newline. Binary records carry their length and checksum, so
+// torn records are detected while reading them.
+func completeIndexSize(indexPath string, format fileFormat) (int64, int64, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := info.Size()
+	if format.binary() {
+		return size, size, nil
+	}
+
+	buf := make([]byte, 4096)
+
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return size, start + int64(i) + 1, nil
+		}
+
+		end = start
+	}
+
+	return size, 0, nil
+}
+
+// truncateToConsistent cuts the data and index files back to the last point
+// at which they agreed with each other
+func truncateToConsistent(dataPath string, indexPath string, dataSize int64, dataEnd int64, indexSize int64, indexEnd int64) (RecoveryReport, error) {
+	report := RecoveryReport{
+		IndexBytesTruncated: indexSize - indexEnd,
+		DataBytesTruncated:  dataSize - dataEnd,
+	}
+
+	if report.IndexBytesTruncated > 0 {
+		if err := os.Truncate(indexPath, indexEnd); err != nil {
+			return report, err
+		}
+	}
+
+	if report.DataBytesTruncated > 0 {
+		if err := os.Truncate(dataPath, dataEnd); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,84 @@
+package onetable
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRecoveryTornIndexRecord(t *testing.T) {
+	folder := t.TempDir()
+	dataPath := path.Join(folder, dataFileName)
+	indexPath := path.Join(folder, indexFileName)
+
+	// second value was written, but its index record was cut short
+	os.WriteFile(dataPath, []byte("val aval b"), 0644)
+	os.WriteFile(indexPath, []byte("a,0,5\nb,5,"), 0644)
+
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report := table.Recovery()
+	if report.IndexBytesTruncated != 4 || report.DataBytesTruncated != 5 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	v, _ := table.Get("a")
+	if string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s", v)
+	}
+
+	if v, _ := table.Get("b"); v != nil {
+		t.Fatal("Key b found after its record was truncated")
+	}
+
+	if err := table.Insert("c", []byte("val c")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if reopened.Recovery().Repaired() {
+		t.Fatalf("Unexpected repair after clean reopen: %s", reopened.Recovery())
+	}
+
+	v, _ = reopened.Get("c")
+	if string(v) != "val c" {
+		t.Fatalf("Expected 'val c', Got %s", v)
+	}
+}
+
+func TestRecoveryMissingValue(t *testing.T) {
+	folder := t.TempDir()
+	dataPath := path.Join(folder, dataFileName)
+	indexPath := path.Join(folder, indexFileName)
+
+	// index record made it to disk, but the value did not
+	os.WriteFile(dataPath, []byte("val aval"), 0644)
+	os.WriteFile(indexPath, []byte("a,0,5\nb,5,5\na,-1,-1\n"), 0644)
+
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report := table.Recovery()
+	if report.IndexBytesTruncated != 14 || report.DataBytesTruncated != 3 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	v, _ := table.Get("a")
+	if string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s", v)
+	}
+
+	info, _ := os.Stat(indexPath)
+	if info.Size() != 6 {
+		t.Fatalf("Expected index of 6 bytes, Got %d", info.Size())
+	}
+}
@@ -0,0 +1,73 @@
+package onetable
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stats describes the contents of a table, see OneTable.Stats
+type Stats struct {
+	// Keys is the number of live keys
+	Keys int
+	// DataBytes is the size of the data file, including the values of
+	// overwritten and deleted keys until the next Compact
+	DataBytes int64
+	// LiveBytes is the size of the values of the live keys. DataBytes minus
+	// LiveBytes is roughly what Compact would reclaim.
+	LiveBytes int64
+	// Tombstones is the number of deletes recorded in the index file since
+	// it was last compacted
+	Tombstones int
+	// IndexType is the Go type of the Index, like *onetable.IndexBST
+	IndexType string
+	// LoadDuration is the time New took to load the table
+	LoadDuration time.Duration
+}
+
+// Stats returns statistics about the table. They are kept up to date by every
+// write, so calling Stats does not scan the table.
+func (o *OneTable) Stats() Stats {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return Stats{
+		Keys:         o.keys,
+		DataBytes:    int64(o.offset),
+		LiveBytes:    o.liveBytes,
+		Tombstones:   o.tombstones,
+		IndexType:    fmt.Sprintf("%T", o.Index),
+		LoadDuration: o.loadDuration,
+	}
+}
+
+// Len returns the number of live keys
+func (o *OneTable) Len() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.keys
+}
+
+// indexInsert stores valueMeta for key in the Index and updates the running
+// totals of Stats
+func (o *OneTable) indexInsert(key string, valueMeta ValueMetadata) error {
+	if old, found := o.Index.Get(key); found {
+		o.liveBytes -= int64(old.Length())
+	} else {
+		o.keys++
+	}
+	o.liveBytes += int64(valueMeta.Length())
+
+	return o.Index.Insert(key, valueMeta)
+}
+
+// indexDelete removes key from the Index and updates the running totals of
+// Stats, except for tombstones, which the caller counts
+func (o *OneTable) indexDelete(key string) error {
+	if old, found := o.Index.Get(key); found {
+		o.keys--
+		o.liveBytes -= int64(old.Length())
+	}
+
+	return o.Index.Delete(key)
+}
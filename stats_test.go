@@ -0,0 +1,97 @@
+package onetable
+
+import (
+	"testing"
+)
+
+func expectStats(t *testing.T, table *OneTable, keys int, liveBytes int64, tombstones int) {
+	t.Helper()
+
+	s := table.Stats()
+	if s.Keys != keys || s.LiveBytes != liveBytes || s.Tombstones != tombstones {
+		t.Fatalf("Expected %d keys, %d live bytes and %d tombstones, Got %+v", keys, liveBytes, tombstones, s)
+	}
+
+	if table.Len() != keys {
+		t.Fatalf("Expected Len %d, Got %d", keys, table.Len())
+	}
+}
+
+func TestStats(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expectStats(t, table, 0, 0, 0)
+
+	table.Insert("a", []byte("12345"))
+	table.Insert("b", []byte("123"))
+	table.Insert("a", []byte("1"))
+	table.Delete("b")
+	table.Delete("missing")
+	expectStats(t, table, 1, 1, 2)
+
+	var batch Batch
+	batch.Put("c", []byte("1234"))
+	batch.Put("d", []byte("12"))
+	batch.Delete("a")
+	if err := table.Write(&batch); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectStats(t, table, 2, 6, 3)
+
+	s := table.Stats()
+	if s.DataBytes != headerSize+5+3+1+4+2 {
+		t.Fatalf("Expected %d data bytes, Got %d", headerSize+15, s.DataBytes)
+	}
+
+	if s.IndexType != "*onetable.IndexBST" {
+		t.Fatalf("Expected index type *onetable.IndexBST, Got %s", s.IndexType)
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectStats(t, reopened, 2, 6, 3)
+
+	if reopened.Stats().LoadDuration <= 0 {
+		t.Fatal("Expected a load duration")
+	}
+
+	if err := reopened.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectStats(t, reopened, 2, 6, 0)
+
+	if s := reopened.Stats(); s.DataBytes != headerSize+6 {
+		t.Fatalf("Expected %d data bytes after Compact, Got %d", headerSize+6, s.DataBytes)
+	}
+}
+
+func TestStatsFromHint(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexAVL())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("123"))
+	table.Insert("b", []byte("123"))
+	table.Delete("a")
+
+	if err := table.Snapshot(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("c", []byte("12"))
+	table.Delete("b")
+
+	reopened, err := New(folder, NewIndexAVL())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectStats(t, reopened, 1, 2, 2)
+}
@@ -0,0 +1,126 @@
+package onetable
+
+import (
+	"errors"
+	"time"
+)
+
+// SyncMode controls when written data is flushed to stable storage
+type SyncMode int
+
+const (
+	// SyncNever leaves flushing to the operating system. An acknowledged
+	// write can be lost on power failure.
+	SyncNever SyncMode = iota
+	// SyncAlways flushes before Insert or Delete returns. Concurrent
+	// writers share a single flush (group commit).
+	SyncAlways
+	// SyncInterval flushes in the background every Options.SyncInterval
+	SyncInterval
+)
+
+// syncUpTo makes sure that all writes up to and including seq are flushed.
+// Callers that arrive while a flush is running wait for it and then either
+// find their write already covered or flush everything written so far in
+// one go.
+func (o *OneTable) syncUpTo(seq uint64) error {
+	o.syncLock.Lock()
+	defer o.syncLock.Unlock()
+
+	if o.synced >= seq {
+		return nil
+	}
+
+	o.lock.Lock()
+	written := o.written
+	o.lock.Unlock()
+
+	if err := o.syncFiles(); err != nil {
+		return err
+	}
+
+	o.synced = written
+	o.syncs++
+
+	return nil
+}
+
+func (o *OneTable) syncFiles() error {
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	if err := o.dataFile.Sync(); err != nil {
+		return err
+	}
+
+	return o.indexFile.Sync()
+}
+
+// commit applies the sync and snapshot policies to the write with sequence
+// number seq
+func (o *OneTable) commit(seq uint64) error {
+	if o.options.Sync == SyncAlways {
+		if err := o.syncUpTo(seq); err != nil {
+			return err
+		}
+	}
+
+	if o.options.SnapshotEvery > 0 && seq%uint64(o.options.SnapshotEvery) == 0 {
+		return o.Snapshot()
+	}
+
+	return nil
+}
+
+// syncPeriodically flushes the files every interval until Close is called.
+// A failed flush is reported by Close.
+func (o *OneTable) syncPeriodically(interval time.Duration) {
+	defer close(o.syncDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.syncStop:
+			return
+		case <-ticker.C:
+			o.lock.Lock()
+			written := o.written
+			o.lock.Unlock()
+
+			if err := o.syncUpTo(written); err != nil {
+				o.syncLock.Lock()
+				o.syncErr = err
+				o.syncLock.Unlock()
+			}
+		}
+	}
+}
+
+// Close stops the background flushing, flushes any outstanding writes and
+// releases the file handles. The table must not be used after Close.
+func (o *OneTable) Close() error {
+	if o.syncStop != nil {
+		close(o.syncStop)
+		<-o.syncDone
+		o.syncStop = nil
+	}
+
+	o.lock.Lock()
+	written := o.written
+	o.lock.Unlock()
+
+	syncErr := o.syncUpTo(written)
+
+	o.syncLock.Lock()
+	if syncErr == nil {
+		syncErr = o.syncErr
+	}
+	o.syncLock.Unlock()
+
+	o.fileLock.Lock()
+	defer o.fileLock.Unlock()
+
+	return errors.Join(syncErr, o.unmap(), o.closeFiles())
+}
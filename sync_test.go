@@ -0,0 +1,132 @@
+package onetable
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncAlwaysGroupCommit(t *testing.T) {
+	folder := t.TempDir()
+	table, err := NewWithOptions(folder, NewIndexHashTable(), Options{Sync: SyncAlways})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// hold back the flushes until every writer has appended its record, so
+	// that they all queue up behind the same one
+	table.syncLock.Lock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", i)
+			if err := table.Insert(key, []byte(key)); err != nil {
+				t.Error(err.Error())
+			}
+		}(i)
+	}
+
+	for {
+		table.lock.Lock()
+		written := table.written
+		table.lock.Unlock()
+		if written == 50 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	table.syncLock.Unlock()
+	wg.Wait()
+
+	if table.synced != table.written {
+		t.Fatalf("Expected all %d writes to be synced, Got %d", table.written, table.synced)
+	}
+
+	if table.syncs != 1 {
+		t.Fatalf("Expected 50 writes to share 1 fsync, Got %d", table.syncs)
+	}
+
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%d", i)
+		v, err := reopened.Get(key)
+		if err != nil || string(v) != key {
+			t.Fatalf("Expected %s, Got %s (%v)", key, v, err)
+		}
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	table, err := NewWithOptions(t.TempDir(), NewIndexBST(), Options{Sync: SyncInterval, SyncInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("a", []byte("val a")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		table.syncLock.Lock()
+		synced := table.synced
+		table.syncLock.Unlock()
+
+		if synced == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Background sync did not flush the insert")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestClose(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("a", []byte("val a")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Insert("b", []byte("val b")); err == nil {
+		t.Fatal("Expected insert into closed table to fail")
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer reopened.Close()
+
+	v, err := reopened.Get("a")
+	if err != nil || string(v) != "val a" {
+		t.Fatalf("Expected 'val a', Got %s (%v)", v, err)
+	}
+}
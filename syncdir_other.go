@@ -0,0 +1,8 @@
+//go:build !unix
+
+package onetable
+
+// syncDir is a no-op where folders cannot be opened for syncing
+func syncDir(folderPath string) error {
+	return nil
+}
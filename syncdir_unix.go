@@ -0,0 +1,17 @@
+//go:build unix
+
+package onetable
+
+import "os"
+
+// syncDir flushes the entries of a folder, making renames and removals in it
+// durable
+func syncDir(folderPath string) error {
+	f, err := os.Open(folderPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return f.Sync()
+}
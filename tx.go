@@ -0,0 +1,326 @@
+package onetable
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrConflict is returned by Update when a key written by the transaction
+// was committed by someone else since the transaction started
+var ErrConflict = errors.New("Transaction conflicts with a concurrent commit")
+
+// ErrTxReadOnly is returned by Put and Delete of a transaction started by View
+var ErrTxReadOnly = errors.New("Transaction is read-only")
+
+// ErrTxClosed is returned when a transaction is used after its function
+// returned
+var ErrTxClosed = errors.New("Transaction is closed")
+
+// Tx is a transaction with snapshot isolation. It reads the table as it was
+// when the transaction started, plus its own writes, which are buffered
+// until commit.
+//
+// Values are never overwritten in the append-only data file, so a snapshot
+// only needs the metadata the Index held when it was taken. While
+// transactions are open, writes keep the metadata they replace in a history
+// (see recordHistory) and Compact waits for the transactions to finish.
+type Tx struct {
+	table    *OneTable
+	seq      uint64
+	writable bool
+	closed   bool
+	writes   map[string]txWrite
+}
+
+type txWrite struct {
+	value  []byte
+	delete bool
+}
+
+// version is the metadata of a key before the write with sequence number seq
+type version struct {
+	seq   uint64
+	value ValueMetadata
+	found bool
+}
+
+// Update runs fn in a read-write transaction. If fn returns nil, its writes
+// are committed atomically like a Batch, unless a concurrent commit wrote
+// one of the same keys since the transaction started, in which case
+// ErrConflict is returned and nothing is written. If fn returns an error,
+// the writes are discarded and the error is returned.
+//
+// fn must not call Compact or Upgrade, which wait for transactions to end.
+func (o *OneTable) Update(fn func(tx *Tx) error) error {
+	tx := o.begin(true)
+	defer tx.end()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// View runs fn in a read-only transaction
+func (o *OneTable) View(fn func(tx *Tx) error) error {
+	tx := o.begin(false)
+	defer tx.end()
+
+	return fn(tx)
+}
+
+func (o *OneTable) begin(writable bool) *Tx {
+	o.txLock.RLock()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.txSeqs == nil {
+		o.txSeqs = make(map[uint64]int)
+		o.history = make(map[string][]version)
+	}
+	o.txSeqs[o.written]++
+
+	return &Tx{table: o, seq: o.written, writable: writable, writes: make(map[string]txWrite)}
+}
+
+func (tx *Tx) end() {
+	o := tx.table
+	tx.closed = true
+
+	o.lock.Lock()
+
+	o.txSeqs[tx.seq]--
+	if o.txSeqs[tx.seq] == 0 {
+		delete(o.txSeqs, tx.seq)
+	}
+
+	if len(o.txSeqs) == 0 {
+		o.txSeqs = nil
+		o.history = nil
+	} else {
+		// versions older than every open snapshot are not needed anymore
+		oldest := tx.seq
+		for seq := range o.txSeqs {
+			oldest = min(oldest, seq)
+		}
+
+		for key, versions := range o.history {
+			i := 0
+			for i < len(versions) && versions[i].seq <= oldest {
+				i++
+			}
+
+			if i == len(versions) {
+				delete(o.history, key)
+			} else {
+				o.history[key] = versions[i:]
+			}
+		}
+	}
+
+	o.lock.Unlock()
+	o.txLock.RUnlock()
+}
+
+// recordHistory keeps the current metadata of key before the write that is
+// about to get the next sequence number. It must be called with lock held.
+func (o *OneTable) recordHistory(key string) {
+	if o.txSeqs == nil {
+		return
+	}
+
+	value, found := o.Index.Get(key)
+	o.history[key] = append(o.history[key], version{seq: o.written + 1, value: value, found: found})
+}
+
+// snapshotGet returns the metadata of key as of sequence number seq. It
+// must be called with lock held.
+func (o *OneTable) snapshotGet(key string, seq uint64) (ValueMetadata, bool) {
+	for _, v := range o.history[key] {
+		if v.seq > seq {
+			return v.value, v.found
+		}
+	}
+
+	return o.Index.Get(key)
+}
+
+// Get returns the value of key in the snapshot, or ErrNotFound
+func (tx *Tx) Get(key string) ([]byte, error) {
+	if tx.closed {
+		return nil, ErrTxClosed
+	}
+
+	if w, ok := tx.writes[key]; ok {
+		if w.delete {
+			return nil, ErrNotFound
+		}
+		return w.value, nil
+	}
+
+	o := tx.table
+
+	o.lock.Lock()
+	valueMeta, found := o.snapshotGet(key, tx.seq)
+	o.lock.Unlock()
+
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	return o.readValue(key, valueMeta)
+}
+
+// Put stores value under key when the transaction commits. The value is
+// copied.
+func (tx *Tx) Put(key string, value []byte) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+
+	if !tx.writable {
+		return ErrTxReadOnly
+	}
+
+	tx.writes[key] = txWrite{value: append([]byte{}, value...)}
+
+	return nil
+}
+
+// Delete removes key when the transaction commits
+func (tx *Tx) Delete(key string) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+
+	if !tx.writable {
+		return ErrTxReadOnly
+	}
+
+	tx.writes[key] = txWrite{delete: true}
+
+	return nil
+}
+
+// Between returns the items of the snapshot with keys from fromKey to toKey
+// inclusive, including the writes of the transaction
+func (tx *Tx) Between(fromKey string, toKey string) ([]*RangeItem, error) {
+	if tx.closed {
+		return nil, ErrTxClosed
+	}
+
+	o := tx.table
+	inRange := func(key string) bool {
+		return key >= fromKey && key <= toKey
+	}
+
+	o.lock.Lock()
+
+	items, err := o.Index.Between(fromKey, toKey)
+	if err != nil {
+		o.lock.Unlock()
+		return nil, err
+	}
+
+	snapshot := make(map[string]ValueMetadata, len(items))
+	for _, it := range items {
+		snapshot[it.Key] = it.Value
+	}
+
+	// undo the writes committed since the snapshot
+	for key := range o.history {
+		if !inRange(key) {
+			continue
+		}
+
+		if valueMeta, found := o.snapshotGet(key, tx.seq); found {
+			snapshot[key] = valueMeta
+		} else {
+			delete(snapshot, key)
+		}
+	}
+
+	o.lock.Unlock()
+
+	keys := make([]string, 0, len(snapshot))
+	for key := range snapshot {
+		if _, written := tx.writes[key]; !written {
+			keys = append(keys, key)
+		}
+	}
+
+	for key, w := range tx.writes {
+		if inRange(key) && !w.delete {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	o.fileLock.RLock()
+	defer o.fileLock.RUnlock()
+
+	ritems := make([]*RangeItem, len(keys))
+	for i, key := range keys {
+		if w, ok := tx.writes[key]; ok {
+			ritems[i] = &RangeItem{Key: key, Value: w.value}
+			continue
+		}
+
+		v, err := o.readValue(key, snapshot[key])
+		if err != nil {
+			return nil, err
+		}
+
+		ritems[i] = &RangeItem{Key: key, Value: v}
+	}
+
+	return ritems, nil
+}
+
+func (tx *Tx) commit() error {
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tx.writes))
+	for key := range tx.writes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var batch Batch
+	for _, key := range keys {
+		if w := tx.writes[key]; w.delete {
+			batch.Delete(key)
+		} else {
+			batch.Put(key, w.value)
+		}
+	}
+
+	o := tx.table
+	o.lock.Lock()
+
+	// first committer wins, every write since the snapshot is in history
+	for _, key := range keys {
+		versions := o.history[key]
+		if len(versions) > 0 && versions[len(versions)-1].seq > tx.seq {
+			o.lock.Unlock()
+			return ErrConflict
+		}
+	}
+
+	seq, err := o.appendBatchLocked(&batch)
+	o.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return o.commit(seq)
+}
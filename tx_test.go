@@ -0,0 +1,250 @@
+package onetable
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestTxSnapshotIsolation(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexBTree(2))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("a1"))
+	table.Insert("b", []byte("b1"))
+
+	started := make(chan struct{})
+	written := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-started
+		table.Insert("a", []byte("a2"))
+		table.Delete("b")
+		table.Insert("c", []byte("c2"))
+		close(written)
+	}()
+
+	err = table.View(func(tx *Tx) error {
+		close(started)
+		<-written
+
+		if v, err := tx.Get("a"); err != nil || string(v) != "a1" {
+			return fmt.Errorf("Expected a1, Got %s (%v)", v, err)
+		}
+
+		if v, err := tx.Get("b"); err != nil || string(v) != "b1" {
+			return fmt.Errorf("Expected b1, Got %s (%v)", v, err)
+		}
+
+		if _, err := tx.Get("c"); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("Expected ErrNotFound for c, Got %v", err)
+		}
+
+		items, err := tx.Between("a", "z")
+		if err != nil {
+			return err
+		}
+
+		if len(items) != 2 || string(items[0].Value) != "a1" || string(items[1].Value) != "b1" {
+			return fmt.Errorf("Unexpected snapshot range of %d items", len(items))
+		}
+
+		if err := tx.Put("d", []byte("d")); !errors.Is(err, ErrTxReadOnly) {
+			return fmt.Errorf("Expected ErrTxReadOnly, Got %v", err)
+		}
+
+		return nil
+	})
+	wg.Wait()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v, _ := table.Get("a"); string(v) != "a2" {
+		t.Fatalf("Expected a2, Got %s", v)
+	}
+
+	if table.history != nil {
+		t.Fatal("History kept after the last transaction ended")
+	}
+}
+
+func TestTxUpdate(t *testing.T) {
+	folder := t.TempDir()
+	table, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("1"))
+	table.Insert("b", []byte("2"))
+
+	err = table.Update(func(tx *Tx) error {
+		tx.Put("c", []byte("3"))
+		tx.Delete("a")
+
+		if _, err := tx.Get("a"); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("Expected own delete to be visible, Got %v", err)
+		}
+
+		items, err := tx.Between("a", "c")
+		if err != nil {
+			return err
+		}
+
+		if len(items) != 2 || items[0].Key != "b" || items[1].Key != "c" {
+			return fmt.Errorf("Unexpected range of %d items", len(items))
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened, err := New(folder, NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	compareTables(t, table, reopened)
+
+	if reopened.Has("a") || !reopened.Has("c") {
+		t.Fatal("Transaction not committed")
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	failure := errors.New("failure")
+
+	var leaked *Tx
+	err = table.Update(func(tx *Tx) error {
+		leaked = tx
+		tx.Put("a", []byte("1"))
+		return failure
+	})
+
+	if err != failure {
+		t.Fatalf("Expected the error of the function, Got %v", err)
+	}
+
+	if table.Has("a") {
+		t.Fatal("Write of a failed transaction committed")
+	}
+
+	if _, err := leaked.Get("a"); !errors.Is(err, ErrTxClosed) {
+		t.Fatalf("Expected ErrTxClosed, Got %v", err)
+	}
+}
+
+func TestTxConflict(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexHashTable())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("counter", []byte("0"))
+
+	err = table.Update(func(tx *Tx) error {
+		v, err := tx.Get("counter")
+		if err != nil {
+			return err
+		}
+
+		// a concurrent committer gets there first
+		if err := table.Insert("counter", []byte("5")); err != nil {
+			return err
+		}
+
+		return tx.Put("counter", append(v, '1'))
+	})
+
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, Got %v", err)
+	}
+
+	if v, _ := table.Get("counter"); string(v) != "5" {
+		t.Fatalf("Expected 5, Got %s", v)
+	}
+}
+
+// TestTxCounter is meant to be run with -race
+func TestTxCounter(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexSkipList())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("counter", []byte{0})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for {
+					err := table.Update(func(tx *Tx) error {
+						v, err := tx.Get("counter")
+						if err != nil {
+							return err
+						}
+						return tx.Put("counter", []byte{v[0] + 1})
+					})
+
+					if err == nil {
+						break
+					}
+
+					if !errors.Is(err, ErrConflict) {
+						t.Error(err.Error())
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if v, _ := table.Get("counter"); v[0] != 160 {
+		t.Fatalf("Expected 160, Got %d", v[0])
+	}
+}
+
+func TestTxStartedAfterDelete(t *testing.T) {
+	table, err := New(t.TempDir(), NewIndexBST())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Insert("a", []byte("a1"))
+
+	// the open transaction keeps history, which the later one must not see
+	err = table.View(func(outer *Tx) error {
+		table.Delete("a")
+
+		return table.View(func(tx *Tx) error {
+			if _, err := tx.Get("a"); !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Expected ErrNotFound, Got %v", err)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}